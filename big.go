@@ -0,0 +1,182 @@
+package jutil
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// The math/big types are serialized by the standard json package with their
+// marshaling methods: *big.Int values are written as numbers by MarshalJSON,
+// *big.Float and *big.Rat values are written as strings by MarshalText.
+//
+// The functions below produce the same representations without going through
+// the methods, which allocate the text of the value on each call.
+
+// jsonLenBigInt computes the length of x once serialized to JSON, it doesn't
+// allocate memory unless x has more than bigPow10CacheLimit digits.
+func jsonLenBigInt(x *big.Int) (n int) {
+	if x == nil {
+		return jsonLenNull()
+	}
+	if x.IsInt64() {
+		return jsonLenInt(x.Int64())
+	}
+	if x.Sign() < 0 {
+		n = 1
+	}
+	return n + bigIntDigits(x)
+}
+
+// jsonLenBigFloat computes the length of x once serialized to JSON. Values with
+// the precision of a float32 or float64 are sized with a single allocation, to
+// convert them with the Float64 method, other values are formatted.
+func jsonLenBigFloat(x *big.Float) (n int) {
+	if x == nil {
+		return jsonLenNull()
+	}
+	if f, bits, ok := bigFloatShortest(x); ok {
+		var b [32]byte
+		return len(strconv.AppendFloat(b[:0], f, 'g', -1, bits)) + 2
+	}
+	// The text is made of digits, signs, dots, and the letters of the
+	// exponent or infinities, none of them are escaped.
+	return len(x.Text('g', -1)) + 2
+}
+
+// jsonLenBigRat computes the length of x once serialized to JSON, it doesn't
+// allocate memory unless its numerator or denominator have more than
+// bigPow10CacheLimit digits.
+func jsonLenBigRat(x *big.Rat) (n int) {
+	if x == nil {
+		return jsonLenNull()
+	}
+	n = jsonLenBigInt(x.Num()) + 2
+	if !x.IsInt() {
+		n += 1 + bigIntDigits(x.Denom())
+	}
+	return
+}
+
+func appendBigInt(dst []byte, x *big.Int) []byte {
+	switch {
+	case x == nil:
+		return append(dst, "null"...)
+	case x.IsInt64():
+		return strconv.AppendInt(dst, x.Int64(), 10)
+	default:
+		return x.Append(dst, 10)
+	}
+}
+
+func appendBigFloat(dst []byte, x *big.Float) []byte {
+	if x == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '"')
+	if f, bits, ok := bigFloatShortest(x); ok {
+		dst = strconv.AppendFloat(dst, f, 'g', -1, bits)
+	} else {
+		dst = x.Append(dst, 'g', -1)
+	}
+	return append(dst, '"')
+}
+
+func appendBigRat(dst []byte, x *big.Rat) []byte {
+	if x == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '"')
+	dst = appendBigInt(dst, x.Num())
+	if !x.IsInt() {
+		dst = append(dst, '/')
+		dst = appendBigInt(dst, x.Denom())
+	}
+	return append(dst, '"')
+}
+
+// bigFloatShortest returns the float64 value of x and the bit size to pass to
+// strconv to produce the same shortest representation as x.Text('g', -1).
+//
+// This is possible for zeros and infinities, and for the values which have
+// the precision of a float32 or float64 and are normal numbers of that type.
+// The mantissas that are powers of two are excluded, the rounding interval
+// used by strconv is narrower below those values than the one used by the
+// math/big package.
+func bigFloatShortest(x *big.Float) (f float64, bits int, ok bool) {
+	var acc big.Accuracy
+	var minExp int
+
+	if x.IsInf() || x.Sign() == 0 {
+		f, _ = x.Float64()
+		return f, 64, true
+	}
+
+	switch x.Prec() {
+	case 24:
+		var f32 float32
+		f32, acc = x.Float32()
+		f, bits, minExp = float64(f32), 32, -125
+	case 53:
+		f, acc = x.Float64()
+		bits, minExp = 64, -1021
+	default:
+		return
+	}
+
+	ok = acc == big.Exact && x.MantExp(nil) >= minExp && x.MinPrec() > 1
+	return
+}
+
+// bigIntDigits returns the number of decimal digits of the absolute value of
+// x, which must not be zero.
+func bigIntDigits(x *big.Int) int {
+	// The absolute value of x is in [2^(b-1), 2^b) so it has either d or d-1
+	// digits, a comparison with the smallest value of d digits tells which.
+	d := int(float64(x.BitLen())*math.Log10(2)) + 1
+	if x.CmpAbs(bigPow10(d-1)) < 0 {
+		d--
+	}
+	return d
+}
+
+// bigPow10CacheLimit is the largest power of ten retained by bigPow10, larger
+// numbers are uncommon enough that allocating the powers on each call is not
+// an issue.
+const bigPow10CacheLimit = 1024
+
+var bigPow10Cache struct {
+	mutex sync.RWMutex
+	store map[int]*big.Int
+}
+
+// bigPow10 returns 10^n, the returned value must not be modified.
+func bigPow10(n int) *big.Int {
+	if n > bigPow10CacheLimit {
+		return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	}
+
+	bigPow10Cache.mutex.RLock()
+	p := bigPow10Cache.store[n]
+	bigPow10Cache.mutex.RUnlock()
+
+	if p == nil {
+		p = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+		bigPow10Cache.mutex.Lock()
+		if bigPow10Cache.store == nil {
+			bigPow10Cache.store = make(map[int]*big.Int)
+		}
+		bigPow10Cache.store[n] = p
+		bigPow10Cache.mutex.Unlock()
+	}
+
+	return p
+}
@@ -0,0 +1,227 @@
+package jutil
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func bigInt(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big.Int: " + s)
+	}
+	return x
+}
+
+func bigRat(s string) *big.Rat {
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("invalid big.Rat: " + s)
+	}
+	return x
+}
+
+func bigFloat(f float64, prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec).SetFloat64(f)
+}
+
+var bigValues = []interface{}{
+	(*big.Int)(nil),
+	new(big.Int),
+	big.NewInt(-1),
+	big.NewInt(math.MaxInt64),
+	big.NewInt(math.MinInt64),
+	bigInt("9223372036854775808"),
+	bigInt("-9223372036854775809"),
+	bigInt("99999999999999999999"),
+	bigInt("100000000000000000000"),
+	bigInt("-100000000000000000000"),
+	bigInt("1" + strings.Repeat("0", 2000)),
+	bigInt(strings.Repeat("9", 2000)),
+
+	(*big.Float)(nil),
+	new(big.Float),
+	big.NewFloat(math.Copysign(0, -1)),
+	big.NewFloat(math.Inf(1)),
+	big.NewFloat(math.Inf(-1)),
+	big.NewFloat(1),
+	big.NewFloat(0.1),
+	big.NewFloat(-1234.5678),
+	big.NewFloat(1e21),
+	big.NewFloat(1e-7),
+	big.NewFloat(123456),
+	big.NewFloat(1234567),
+	big.NewFloat(math.MaxFloat64),
+	big.NewFloat(math.SmallestNonzeroFloat64),
+	bigFloat(0.1, 24),
+	bigFloat(0.1, 100),
+	bigFloat(1.0/3, 8),
+	new(big.Float).SetInt(bigInt("123456789012345678901234567890")),
+
+	(*big.Rat)(nil),
+	new(big.Rat),
+	big.NewRat(1, 3),
+	big.NewRat(-22, 7),
+	big.NewRat(10, 1),
+	bigRat("-12345678901234567890123/10000000000000000000000"),
+
+	struct {
+		A *big.Int
+		B *big.Float `json:",omitempty"`
+		C *big.Rat
+		D []*big.Int
+	}{A: big.NewInt(42), C: big.NewRat(1, 2), D: []*big.Int{nil, bigInt("123456789012345678901234567890")}},
+}
+
+func TestLengthBig(t *testing.T) {
+	for _, test := range bigValues {
+		b, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := Length(test); err != nil || n != len(b) {
+			t.Errorf("Length: %s: %d != %d (%v)", b, len(b), n, err)
+		}
+
+		if n, err := LengthWithOptions(test, StdLengthOptions); err != nil || n != len(b) {
+			t.Errorf("LengthWithOptions: %s: %d != %d (%v)", b, len(b), n, err)
+		}
+
+		if m, err := Marshal(test); err != nil || string(m) != string(b) {
+			t.Errorf("Marshal: %s != %s (%v)", b, m, err)
+		}
+	}
+}
+
+func TestLengthBigAddressable(t *testing.T) {
+	type withBig struct {
+		I big.Int
+		F big.Float
+		R big.Rat
+	}
+
+	v := &withBig{}
+	v.I.SetInt64(-42)
+	v.F.SetFloat64(0.5)
+	v.R.SetFrac64(1, 3)
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := LengthWithOptions(v, StdLengthOptions); err != nil || n != len(b) {
+		t.Errorf("%s: %d != %d (%v)", b, len(b), n, err)
+	}
+	if m, err := Marshal(v); err != nil || string(m) != string(b) {
+		t.Errorf("Marshal: %s != %s (%v)", b, m, err)
+	}
+}
+
+func TestLengthBigRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	for i := 0; i != 1000; i++ {
+		x := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(r.Intn(512))))
+		if r.Intn(2) == 0 {
+			x.Neg(x)
+		}
+		y := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(r.Intn(128))))
+		y.Add(y, big.NewInt(1))
+
+		f := math.Float64frombits(r.Uint64())
+		if math.IsNaN(f) {
+			f = 0
+		}
+
+		for _, v := range []interface{}{
+			x,
+			new(big.Rat).SetFrac(x, y),
+			big.NewFloat(f),
+			bigFloat(f, 24),
+			bigFloat(f, uint(1+r.Intn(100))),
+			new(big.Float).SetInt(x),
+		} {
+			b, _ := json.Marshal(v)
+
+			if n, err := Length(v); err != nil || n != len(b) {
+				t.Fatalf("Length: %s: %d != %d (%v)", b, len(b), n, err)
+			}
+
+			if m, err := Marshal(v); err != nil || string(m) != string(b) {
+				t.Fatalf("Marshal: %s != %s (%v)", b, m, err)
+			}
+		}
+	}
+}
+
+func TestLengthBigAllocs(t *testing.T) {
+	tests := []struct {
+		v      interface{}
+		allocs float64
+	}{
+		{big.NewInt(-42), 0},
+		{bigInt("-123456789012345678901234567890"), 0},
+		{big.NewRat(-22, 7), 0},
+		{bigRat("12345678901234567890123/10000000000000000000000"), 0},
+		{new(big.Float), 0},
+		{big.NewFloat(1234.5678), 1}, // the Float64 method copies the mantissa
+		{bigFloat(0.1, 24), 1},
+	}
+
+	for _, test := range tests {
+		Length(test.v) // warm up the cache of powers of ten
+
+		if n := testing.AllocsPerRun(100, func() { Length(test.v) }); n > test.allocs {
+			t.Errorf("%v: too many memory allocations: %v", test.v, n)
+		}
+	}
+}
+
+func FuzzLengthBigFloat(f *testing.F) {
+	for _, v := range []float64{0, 1, -0.5, 0.1, 1e20, 1e21, 1e-6, 1e-7, math.MaxFloat64, math.SmallestNonzeroFloat64} {
+		f.Add(math.Float64bits(v), uint(53))
+	}
+
+	f.Fuzz(func(t *testing.T, bits uint64, prec uint) {
+		v := math.Float64frombits(bits)
+		if math.IsNaN(v) {
+			return
+		}
+
+		x := bigFloat(v, prec%128)
+		b, _ := json.Marshal(x)
+
+		if n, err := Length(x); err != nil || n != len(b) {
+			t.Fatalf("%s: %d != %d (%v)", b, len(b), n, err)
+		}
+	})
+}
+
+func BenchmarkLengthBigInt(b *testing.B) {
+	x := bigInt("-123456789012345678901234567890")
+	n := 0
+
+	for i := 0; i != b.N; i++ {
+		n += jsonLenBigInt(x)
+	}
+
+	benchSink = n
+}
+
+func BenchmarkLengthBigIntMarshalJSON(b *testing.B) {
+	x := bigInt("-123456789012345678901234567890")
+	n := 0
+
+	for i := 0; i != b.N; i++ {
+		m, _ := x.MarshalJSON()
+		n += len(m)
+	}
+
+	benchSink = n
+}
@@ -0,0 +1,844 @@
+package jutil
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Canonicalize returns the canonical representation of the JSON document in src
+// as defined by the JSON Canonicalization Scheme (JCS) of RFC 8785.
+//
+// Object members are sorted by the UTF-16 code units of their keys, numbers
+// are formatted like ECMAScript does, and strings use the minimal escaping of
+// JSON.stringify. The function returns an error if the document contains
+// duplicate object keys or numbers that don't fit in an IEEE 754 double.
+func Canonicalize(src []byte) ([]byte, error) {
+	dst, err := appendCanonicalized(make([]byte, 0, len(src)), src)
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// MarshalCanonical returns the canonical representation of v, it is a shortcut
+// for calling Marshal on an Encoder with the Canonical option set.
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	e := Encoder{Canonical: true}
+	return e.Marshal(v)
+}
+
+// appendCanonicalized appends the canonical representation of the JSON document
+// in src to dst.
+func appendCanonicalized(dst []byte, src []byte) ([]byte, error) {
+	c := canonicalizer{}
+	c.tok.Reset(src)
+
+	dst, err := c.value(dst)
+	if err != nil {
+		return dst, err
+	}
+
+	if c.tok.Next() {
+		return dst, syntaxErrorChar(src, c.tok.Offset, ctxEnd)
+	}
+
+	return dst, c.tok.Err
+}
+
+// CanonicalLength computes the length of the canonical representation of a
+// value of arbitrary type, it is consistent with the output of
+// MarshalCanonical the same way Length is with the output of the standard json
+// package.
+func CanonicalLength(v interface{}) (n int, err error) {
+	var b []byte
+
+	switch x := v.(type) {
+	case nil:
+		n = jsonLenNull()
+
+	case bool:
+		n = jsonLenBool(x)
+
+	case int:
+		n = canonicalLenNumber(float64(x))
+
+	case int64:
+		n = canonicalLenNumber(float64(x))
+
+	case uint64:
+		n = canonicalLenNumber(float64(x))
+
+	case float32:
+		n, err = canonicalLenFloat(float64(x), 32)
+
+	case float64:
+		n, err = canonicalLenFloat(x, 64)
+
+	case string:
+		n = canonicalLenString(x)
+
+	case []byte:
+		n = canonicalLenBytes(x)
+
+	case json.Number:
+		var a [32]byte
+		if b, err = appendCanonicalJSONNumber(a[:0], x); err == nil {
+			n = len(b)
+		}
+
+	case OptionsAppender, Appender, json.Marshaler, encoding.TextMarshaler:
+		if b, err = MarshalCanonical(x); err == nil {
+			n = len(b)
+		}
+
+	default:
+		n, err = canonicalLenV(reflect.ValueOf(v))
+	}
+
+	return
+}
+
+func canonicalLenV(v reflect.Value) (n int, err error) {
+	switch t := v.Type(); t.Kind() {
+	case reflect.Struct:
+		var c int
+
+		for _, f := range LookupStruct(t) {
+			fv := v.FieldByIndex(f.Index)
+
+			if f.Omit(fv) {
+				continue
+			}
+			if c, err = canonicalLenValue(fv); err != nil {
+				return
+			}
+			if n != 0 {
+				n++
+			}
+			n += canonicalLenString(f.Name) + c + 1
+		}
+
+		n += 2
+
+	case reflect.Map:
+		var c1, c2 int
+
+		if v.IsNil() {
+			return jsonLenNull(), nil
+		}
+
+		if !isMapKeyType(t.Key()) {
+			return 0, &json.UnsupportedTypeError{Type: t}
+		}
+
+		for i, it := 0, v.MapRange(); it.Next(); i++ {
+			var key string
+			if i != 0 {
+				n++
+			}
+			if key, err = resolveMapKey(it.Key()); err != nil {
+				return
+			}
+			c1 = canonicalLenString(key)
+			if c2, err = canonicalLenValue(it.Value()); err != nil {
+				return
+			}
+			n += c1 + c2 + 1
+		}
+
+		n += 2
+
+	case reflect.Slice, reflect.Array:
+		var c int
+
+		if t.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return jsonLenNull(), nil
+			}
+			if t.Elem().Kind() == reflect.Uint8 && !isMarshalerType(reflect.PtrTo(t.Elem())) {
+				return canonicalLenBytes(v.Bytes()), nil
+			}
+		}
+
+		for i, j := 0, v.Len(); i != j; i++ {
+			if i != 0 {
+				n++
+			}
+			if c, err = canonicalLenValue(v.Index(i)); err != nil {
+				return
+			}
+			n += c
+		}
+
+		n += 2
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			n = jsonLenNull()
+		} else {
+			n, err = canonicalLenValue(v.Elem())
+		}
+
+	case reflect.Bool:
+		n = jsonLenBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = canonicalLenNumber(float64(v.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = canonicalLenNumber(float64(v.Uint()))
+
+	case reflect.Float32:
+		n, err = canonicalLenFloat(v.Float(), 32)
+
+	case reflect.Float64:
+		n, err = canonicalLenFloat(v.Float(), 64)
+
+	case reflect.String:
+		n = canonicalLenString(v.String())
+
+	default:
+		err = &json.UnsupportedTypeError{Type: t}
+	}
+
+	return
+}
+
+// canonicalLenValue computes the canonical length of a value that was obtained
+// through reflection, if it is addressable the methods of its pointer type are
+// used like the standard json package does.
+func canonicalLenValue(v reflect.Value) (int, error) {
+	if !v.CanInterface() {
+		return 0, fmt.Errorf("reflect: cannot call Interface on %v", v)
+	}
+
+	if v.CanAddr() {
+		p := v.Addr()
+
+		if isEncoderMethodsType(p.Type()) {
+			return CanonicalLength(p.Interface())
+		}
+
+		// Structs and arrays are walked with the addressable value so the
+		// pointer methods of their fields and elements are also used.
+		switch v.Kind() {
+		case reflect.Struct, reflect.Array:
+			return canonicalLenV(v)
+		}
+	}
+
+	return CanonicalLength(v.Interface())
+}
+
+func canonicalLenNumber(f float64) int {
+	var b [32]byte
+	return len(appendCanonicalNumber(b[:0], f, 64))
+}
+
+func canonicalLenFloat(f float64, bits int) (int, error) {
+	var b [32]byte
+	d, err := appendCanonicalFloat(b[:0], f, bits)
+	return len(d), err
+}
+
+func canonicalLenString(s string) (n int) {
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"', c == '\\', c == '\b', c == '\f', c == '\n', c == '\r', c == '\t':
+				n += 2
+			case c < 0x20:
+				n += 6
+			default:
+				n++
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			n += 3 // replaced with U+FFFD
+		} else {
+			n += size
+		}
+		i += size
+	}
+	return n + 2
+}
+
+func canonicalLenBytes(b []byte) int {
+	if b == nil {
+		return jsonLenNull()
+	}
+	return 2 + base64.StdEncoding.EncodedLen(len(b))
+}
+
+// appendCanonical is the implementation of Append for encoders which have the
+// Canonical option set, it mirrors CanonicalLength.
+func (e *Encoder) appendCanonical(dst []byte, v interface{}) ([]byte, error) {
+	var b []byte
+	var err error
+
+	switch x := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+
+	case bool:
+		return strconv.AppendBool(dst, x), nil
+
+	case int:
+		return appendCanonicalNumber(dst, float64(x), 64), nil
+
+	case int64:
+		return appendCanonicalNumber(dst, float64(x), 64), nil
+
+	case uint64:
+		return appendCanonicalNumber(dst, float64(x), 64), nil
+
+	case float32:
+		return appendCanonicalFloat(dst, float64(x), 32)
+
+	case float64:
+		return appendCanonicalFloat(dst, x, 64)
+
+	case string:
+		return appendCanonicalString(dst, x), nil
+
+	case []byte:
+		return appendBytes(dst, x), nil
+
+	case json.Number:
+		return appendCanonicalJSONNumber(dst, x)
+
+	case OptionsAppender, Appender, json.Marshaler, encoding.TextMarshaler:
+		if isNilPointer(x) {
+			return append(dst, "null"...), nil
+		}
+
+		// The JSON written by the methods is canonicalized, the values that
+		// it contains are written by an encoder without options so they are
+		// canonicalized only once.
+		switch m := x.(type) {
+		case OptionsAppender:
+			b, err = m.AppendJSONWithOptions(nil, new(Encoder))
+		case Appender:
+			b = m.AppendJSON(nil)
+		case json.Marshaler:
+			b, err = m.MarshalJSON()
+		case encoding.TextMarshaler:
+			if b, err = m.MarshalText(); err != nil {
+				return dst, &json.MarshalerError{Type: reflect.TypeOf(x), Err: err}
+			}
+			return appendCanonicalString(dst, string(b)), nil
+		}
+
+		if err == nil {
+			dst, err = appendCanonicalized(dst, b)
+		}
+		if err != nil {
+			err = &json.MarshalerError{Type: reflect.TypeOf(x), Err: err}
+		}
+		return dst, err
+
+	default:
+		return e.appendCanonicalV(dst, reflect.ValueOf(v))
+	}
+}
+
+func (e *Encoder) appendCanonicalV(dst []byte, v reflect.Value) ([]byte, error) {
+	switch t := v.Type(); t.Kind() {
+	case reflect.Struct:
+		return e.appendCanonicalStruct(dst, t, v)
+
+	case reflect.Map:
+		return e.appendCanonicalMap(dst, v)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !isMarshalerType(reflect.PtrTo(t.Elem())) {
+			return appendBytes(dst, v.Bytes()), nil // []byte
+		}
+		return e.appendCanonicalArray(dst, v)
+
+	case reflect.Array:
+		return e.appendCanonicalArray(dst, v)
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		return e.appendCanonicalElem(dst, v.Elem())
+
+	case reflect.Bool:
+		return strconv.AppendBool(dst, v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendCanonicalNumber(dst, float64(v.Int()), 64), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendCanonicalNumber(dst, float64(v.Uint()), 64), nil
+
+	case reflect.Float32:
+		return appendCanonicalFloat(dst, v.Float(), 32)
+
+	case reflect.Float64:
+		return appendCanonicalFloat(dst, v.Float(), 64)
+
+	case reflect.String:
+		return appendCanonicalString(dst, v.String()), nil
+
+	default:
+		return dst, &json.UnsupportedTypeError{Type: t}
+	}
+}
+
+// appendCanonicalElem is the equivalent of appendElem for the canonical
+// representation.
+func (e *Encoder) appendCanonicalElem(dst []byte, v reflect.Value) ([]byte, error) {
+	if !v.CanInterface() {
+		return dst, fmt.Errorf("reflect: cannot call Interface on %v", v)
+	}
+
+	if v.CanAddr() {
+		p := v.Addr()
+
+		if isEncoderMethodsType(p.Type()) {
+			return e.appendCanonical(dst, p.Interface())
+		}
+
+		switch v.Kind() {
+		case reflect.Struct, reflect.Array:
+			return e.appendCanonicalV(dst, v)
+		}
+	}
+
+	return e.appendCanonical(dst, v.Interface())
+}
+
+func (e *Encoder) appendCanonicalArray(dst []byte, v reflect.Value) ([]byte, error) {
+	var err error
+
+	dst = append(dst, '[')
+
+	for i, j := 0, v.Len(); i != j; i++ {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		if dst, err = e.appendCanonicalElem(dst, v.Index(i)); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, ']'), nil
+}
+
+func (e *Encoder) appendCanonicalStruct(dst []byte, t reflect.Type, v reflect.Value) ([]byte, error) {
+	buf := mapEntryPool.Get().(*mapEntries)
+	entries := (*buf)[:0]
+
+	for _, f := range LookupStruct(t) {
+		if fv := v.FieldByIndex(f.Index); !f.Omit(fv) {
+			entries = append(entries, mapEntry{key: f.Name, value: fv})
+		}
+	}
+
+	dst, err := e.appendCanonicalObject(dst, entries)
+	releaseMapEntries(buf, entries)
+	return dst, err
+}
+
+func (e *Encoder) appendCanonicalMap(dst []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return append(dst, "null"...), nil
+	}
+
+	if !isMapKeyType(v.Type().Key()) {
+		return dst, &json.UnsupportedTypeError{Type: v.Type()}
+	}
+
+	buf := mapEntryPool.Get().(*mapEntries)
+	entries := (*buf)[:0]
+
+	for it := v.MapRange(); it.Next(); {
+		k, err := resolveMapKey(it.Key())
+		if err != nil {
+			releaseMapEntries(buf, entries)
+			return dst, err
+		}
+		entries = append(entries, mapEntry{key: k, value: it.Value()})
+	}
+
+	dst, err := e.appendCanonicalObject(dst, entries)
+	releaseMapEntries(buf, entries)
+	return dst, err
+}
+
+// appendCanonicalObject writes an object with the given members, sorted by the
+// UTF-16 code units of their keys.
+func (e *Encoder) appendCanonicalObject(dst []byte, entries []mapEntry) ([]byte, error) {
+	var err error
+
+	sort.Sort((*canonicalEntries)(&entries))
+
+	dst = append(dst, '{')
+
+	for i, entry := range entries {
+		if i != 0 {
+			if entry.key == entries[i-1].key {
+				return dst, duplicateKeyError(entry.key)
+			}
+			dst = append(dst, ',')
+		}
+		dst = appendCanonicalString(dst, entry.key)
+		dst = append(dst, ':')
+		if dst, err = e.appendCanonicalElem(dst, entry.value); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+type canonicalEntries []mapEntry
+
+func (m *canonicalEntries) Len() int           { return len(*m) }
+func (m *canonicalEntries) Less(i, j int) bool { return compareUTF16((*m)[i].key, (*m)[j].key) < 0 }
+func (m *canonicalEntries) Swap(i, j int)      { (*m)[i], (*m)[j] = (*m)[j], (*m)[i] }
+
+type canonicalizer struct {
+	tok Tokenizer
+}
+
+type canonicalMember struct {
+	key   string
+	value []byte
+}
+
+func (c *canonicalizer) value(dst []byte) ([]byte, error) {
+	if err := c.next(); err != nil {
+		return dst, err
+	}
+	return c.current(dst)
+}
+
+func (c *canonicalizer) next() error {
+	if !c.tok.Next() {
+		if c.tok.Err != nil {
+			return c.tok.Err
+		}
+		return syntaxErrorChar(c.tok.input, c.tok.Pos(), ctxValue)
+	}
+	return nil
+}
+
+// current writes the value starting at the current token.
+func (c *canonicalizer) current(dst []byte) ([]byte, error) {
+	t := &c.tok
+
+	switch t.Kind {
+	case ObjectStart:
+		return c.object(dst)
+
+	case ArrayStart:
+		return c.array(dst)
+
+	case String:
+		s, err := Unquote(t.Value)
+		if err != nil {
+			return dst, err
+		}
+		return appendCanonicalString(dst, string(s)), nil
+
+	case Number:
+		f, err := strconv.ParseFloat(string(t.Value), 64)
+		if err != nil {
+			return dst, canonicalNumberError(string(t.Value))
+		}
+		return appendCanonicalNumber(dst, f, 64), nil
+
+	default: // true, false, null
+		return append(dst, t.Value...), nil
+	}
+}
+
+func (c *canonicalizer) array(dst []byte) ([]byte, error) {
+	var err error
+	t := &c.tok
+	dst = append(dst, '[')
+
+	for {
+		if err = c.next(); err != nil {
+			return dst, err
+		}
+		switch t.Kind {
+		case Comma:
+			dst = append(dst, ',')
+		case ArrayEnd:
+			return append(dst, ']'), nil
+		default:
+			if dst, err = c.current(dst); err != nil {
+				return dst, err
+			}
+		}
+	}
+}
+
+func (c *canonicalizer) object(dst []byte) ([]byte, error) {
+	t := &c.tok
+	members := []canonicalMember{}
+
+	for {
+		if err := c.next(); err != nil {
+			return dst, err
+		}
+
+		if t.Kind == ObjectEnd {
+			break
+		}
+
+		if t.Kind == Comma {
+			continue
+		}
+
+		k, err := Unquote(t.Value)
+		if err != nil {
+			return dst, err
+		}
+
+		if err := c.next(); err != nil { // colon
+			return dst, err
+		}
+
+		v, err := c.value(nil)
+		if err != nil {
+			return dst, err
+		}
+
+		members = append(members, canonicalMember{key: string(k), value: v})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return compareUTF16(members[i].key, members[j].key) < 0
+	})
+
+	dst = append(dst, '{')
+
+	for i, m := range members {
+		if i != 0 {
+			if m.key == members[i-1].key {
+				return dst, duplicateKeyError(m.key)
+			}
+			dst = append(dst, ',')
+		}
+		dst = appendCanonicalString(dst, m.key)
+		dst = append(dst, ':')
+		dst = append(dst, m.value...)
+	}
+
+	return append(dst, '}'), nil
+}
+
+// appendCanonicalString appends s to dst as a JSON string, escaping only the
+// characters that JSON.stringify escapes.
+func appendCanonicalString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, "�"...)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			if c < 0x20 {
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			} else {
+				dst = append(dst, c)
+			}
+		}
+
+		i++
+	}
+
+	return append(dst, '"')
+}
+
+// appendCanonicalFloat is like appendCanonicalNumber but returns an error if f
+// is NaN or an infinity.
+func appendCanonicalFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return dst, &json.UnsupportedValueError{
+			Value: reflect.ValueOf(f),
+			Str:   strconv.FormatFloat(f, 'g', -1, bits),
+		}
+	}
+	return appendCanonicalNumber(dst, f, bits), nil
+}
+
+// appendCanonicalJSONNumber appends the canonical representation of x to dst,
+// empty numbers are written as 0 like the encoders do.
+func appendCanonicalJSONNumber(dst []byte, x json.Number) ([]byte, error) {
+	if len(x) == 0 {
+		return append(dst, '0'), nil
+	}
+	if !isValidNumber(string(x)) {
+		return dst, fmt.Errorf("json: invalid number literal %q", string(x))
+	}
+	f, err := strconv.ParseFloat(string(x), 64)
+	if err != nil {
+		return dst, canonicalNumberError(string(x))
+	}
+	return appendCanonicalNumber(dst, f, 64), nil
+}
+
+// appendCanonicalNumber appends f to dst formatted like ECMAScript's
+// Number.prototype.toString does. When bits is 32 the number is formatted
+// with the shortest representation of the float32 value, which is what the
+// encoders write. Parsing it as a float64 and formatting that value again, as
+// Canonicalize does, gives the same digits for every float32 value.
+func appendCanonicalNumber(dst []byte, f float64, bits int) []byte {
+	if f == 0 {
+		return append(dst, '0') // also covers -0
+	}
+
+	if f < 0 {
+		dst = append(dst, '-')
+		f = -f
+	}
+
+	// The shortest decimal representation is d.ddddde±xx, extract the digits
+	// and the exponent so the number can be laid out like ECMAScript does.
+	var b [32]byte
+	e := strconv.AppendFloat(b[:0], f, 'e', -1, bits)
+	x := 0
+	for i := len(e) - 1; ; i-- {
+		if e[i] == 'e' {
+			x, _ = strconv.Atoi(string(e[i+1:]))
+			e = e[:i]
+			break
+		}
+	}
+
+	var digits [24]byte
+	k := 0
+	for _, c := range e {
+		if c != '.' {
+			digits[k] = c
+			k++
+		}
+	}
+
+	n := x + 1 // position of the decimal point relative to the digits
+
+	switch {
+	case k <= n && n <= 21:
+		dst = append(dst, digits[:k]...)
+		for i := k; i < n; i++ {
+			dst = append(dst, '0')
+		}
+
+	case 0 < n && n <= 21:
+		dst = append(dst, digits[:n]...)
+		dst = append(dst, '.')
+		dst = append(dst, digits[n:k]...)
+
+	case -6 < n && n <= 0:
+		dst = append(dst, '0', '.')
+		for i := n; i < 0; i++ {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits[:k]...)
+
+	default:
+		dst = append(dst, digits[0])
+		if k > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:k]...)
+		}
+		dst = append(dst, 'e')
+		if x >= 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(x), 10)
+	}
+
+	return dst
+}
+
+func canonicalNumberError(s string) error {
+	return fmt.Errorf("jutil: number %s cannot be represented as an IEEE 754 double", s)
+}
+
+func duplicateKeyError(key string) error {
+	return fmt.Errorf("jutil: duplicate object key %q", key)
+}
+
+// compareUTF16 compares two strings by their UTF-16 code units.
+func compareUTF16(a string, b string) int {
+	for len(a) != 0 && len(b) != 0 {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+
+		if ra != rb {
+			ua, ub := utf16Unit(ra), utf16Unit(rb)
+			if ua == ub { // same high surrogate
+				ua, ub = ra, rb
+			}
+			if ua < ub {
+				return -1
+			}
+			return 1
+		}
+
+		a, b = a[na:], b[nb:]
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// utf16Unit returns the first UTF-16 code unit of the encoding of r.
+func utf16Unit(r rune) rune {
+	if r >= 0x10000 {
+		return 0xD800 + ((r - 0x10000) >> 10)
+	}
+	return r
+}
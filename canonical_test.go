@@ -0,0 +1,311 @@
+package jutil
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCanonicalNumber(t *testing.T) {
+	// Test vectors from appendix B of RFC 8785.
+	tests := []struct {
+		bits   uint64
+		expect string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+
+	for _, test := range tests {
+		f := math.Float64frombits(test.bits)
+
+		if s := string(appendCanonicalNumber(nil, f, 64)); s != test.expect {
+			t.Errorf("%016x: invalid number representation: %s != %s", test.bits, test.expect, s)
+		}
+
+		if n, err := CanonicalLength(f); err != nil {
+			t.Errorf("%016x: %s", test.bits, err)
+		} else if n != len(test.expect) {
+			t.Errorf("%016x: invalid length: %d != %d", test.bits, len(test.expect), n)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect string
+	}{
+		{`null`, `null`},
+		{` [ 1 , 2.50 , -0 , 1E2 ] `, `[1,2.5,0,100]`},
+		{`{}`, `{}`},
+		{`[]`, `[]`},
+		{`[[],[{}]]`, `[[],[{}]]`},
+		{`{"b":[{"d":1,"c":2}],"a":{}}`, `{"a":{},"b":[{"c":2,"d":1}]}`},
+		{`"\u00e9\/\u001F\u2028"`, "\"é/\\u001f\u2028\""},
+
+		// Example from section 3.2.2 of RFC 8785.
+		{`{
+  "numbers": [333333333.33333329, 1E30, 4.50,
+              2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+
+		// Sorting example from section 3.2.3 of RFC 8785.
+		{`{
+  "\u20ac": "Euro Sign",
+  "\r": "Carriage Return",
+  "\ufb33": "Hebrew Letter Dalet With Dagesh",
+  "1": "One",
+  "\ud83d\ude00": "Emoji: Grinning Face",
+  "\u0080": "Control",
+  "\u00f6": "Latin Small Letter O With Diaeresis"
+}`, "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+	}
+
+	for _, test := range tests {
+		if b, err := Canonicalize([]byte(test.in)); err != nil {
+			t.Errorf("%.40q: %s", test.in, err)
+		} else if string(b) != test.expect {
+			t.Errorf("%.40q: invalid canonical output:\n%s\n%s", test.in, test.expect, string(b))
+		}
+	}
+}
+
+func TestCanonicalizeError(t *testing.T) {
+	tests := []string{
+		``,
+		`[1,]`,
+		`{"a":1,"a":2}`,
+		`{"a":{"b":1,"b":1}}`,
+		`1e400`,
+		`true false`,
+	}
+
+	for _, test := range tests {
+		if _, err := Canonicalize([]byte(test)); err == nil {
+			t.Errorf("%q: expected an error", test)
+		}
+	}
+}
+
+type canonicalKey int
+
+func (k canonicalKey) MarshalText() ([]byte, error) {
+	return []byte("key-" + strconv.Itoa(int(k))), nil
+}
+
+type canonicalPtrMarshaler struct{ S string }
+
+func (m *canonicalPtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"s":"` + m.S + `","n":1.50}`), nil
+}
+
+func TestCanonicalLength(t *testing.T) {
+	type pointerMethods struct {
+		A canonicalPtrMarshaler
+		B [1]canonicalPtrMarshaler
+		C []canonicalPtrMarshaler
+		D map[string]canonicalPtrMarshaler
+		E []encodeByte
+	}
+
+	p := pointerMethods{
+		C: []canonicalPtrMarshaler{{S: "c"}},
+		D: map[string]canonicalPtrMarshaler{"d": {S: "d"}},
+		E: []encodeByte{0, 1},
+	}
+
+	tests := []interface{}{
+		nil,
+		true,
+		0,
+		-1,
+		uint64(math.MaxUint64),
+		1e21,
+		0.1,
+		float32(0.1),
+		[]float32{1e21, 3.4028235e38, 1e-45, 16777217, -0.3},
+		json.Number(""),
+		json.Number("1.50"),
+		[]json.Number{"-0", "1e2"},
+		"",
+		"Hello World!",
+		"<\"\\\n\x01\u2028é😀\xff>",
+		[]byte("Hello World!"),
+		[]int{1, 2, 3},
+		[2]float64{1e-7, 1e30},
+		map[string]interface{}{"z": 1, "a": []interface{}{"b", nil}},
+		map[int]string{10: "A", 2: "B"},
+		map[canonicalKey]int{1: 1, 20: 2},
+		map[encodeKey]string{3: "c", 1: "a"},
+		struct {
+			A int
+			B string `json:"b,omitempty"`
+			C []string
+			d int
+		}{A: 1, C: []string{"€"}},
+		&struct{ Value *int }{},
+		p,
+		&p,
+		[]pointerMethods{p},
+		time.Date(2016, 11, 3, 10, 2, 0, 123, time.UTC),
+		bigInt("123456789012345678901234567890"),
+	}
+
+	for _, test := range tests {
+		b, err := MarshalCanonical(test)
+		if err != nil {
+			t.Errorf("%#v: %s", test, err)
+			continue
+		}
+
+		// The canonical encoder produces the same output as canonicalizing
+		// the output of the standard json package.
+		if j, err := json.Marshal(test); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if c, err := Canonicalize(j); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if string(c) != string(b) {
+			t.Errorf("%#v: invalid canonical output:\n%s\n%s", test, c, b)
+		}
+
+		if n, err := CanonicalLength(test); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if n != len(b) {
+			t.Errorf("%#v: invalid length: %d != %d (%s)", test, len(b), n, b)
+		}
+	}
+}
+
+func TestCanonicalLengthAllocs(t *testing.T) {
+	for _, v := range []interface{}{float32(0.1), 1e21, json.Number("-1.50"), 42} {
+		if n := testing.AllocsPerRun(100, func() { CanonicalLength(v) }); n != 0 {
+			t.Errorf("%#v: too many memory allocations: %v", v, n)
+		}
+	}
+}
+
+func TestCanonicalLengthError(t *testing.T) {
+	tests := []interface{}{
+		math.NaN(),
+		float32(math.Inf(1)),
+		[]float32{float32(math.NaN())},
+		json.Number("abc"),
+		json.Number("1e400"),
+		make(chan int),
+		map[float64]int{1: 1},
+	}
+
+	for _, test := range tests {
+		if _, err := CanonicalLength(test); err == nil {
+			t.Errorf("%#v: expected an error from CanonicalLength", test)
+		}
+		if _, err := MarshalCanonical(test); err == nil {
+			t.Errorf("%#v: expected an error from MarshalCanonical", test)
+		}
+	}
+}
+
+func TestEncoderCanonical(t *testing.T) {
+	m := &OrderedMap{}
+	m.Set("b", 1)
+	m.Set("a", []interface{}{1.50, "\u2028"})
+
+	tests := []struct {
+		v      interface{}
+		expect string
+	}{
+		{m, "{\"a\":[1.5,\"\u2028\"],\"b\":1}"},
+		{Value(`{"z":1e2,"a":"<\/>"}`), `{"a":"</>","z":100}`},
+		{encodeAppender{}, `"appender"`},
+		{map[string]interface{}{"x": encodeOptionsAppender{S: "<>"}, "ab": Value("1.0")}, `{"ab":1,"x":"<>"}`},
+		{struct {
+			Z int64
+			A int64 `json:"\u20ac"`
+			B uint8 `json:"\U0001f600"`
+		}{Z: 1 << 60, A: -1, B: 2}, "{\"Z\":1152921504606847000,\"\u20ac\":-1,\"\U0001f600\":2}"},
+	}
+
+	for _, test := range tests {
+		e := Encoder{Canonical: true, EscapeHTML: true}
+
+		b, err := e.Marshal(test.v)
+		if err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if string(b) != test.expect {
+			t.Errorf("%#v: invalid canonical output:\n%s\n%s", test.v, test.expect, b)
+		}
+
+		if n, err := CanonicalLength(test.v); err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+		} else if n != len(b) {
+			t.Errorf("%#v: invalid length: %d != %d (%s)", test.v, len(b), n, b)
+		}
+	}
+
+	if _, err := MarshalCanonical(map[encodeKey]int{2: 1, 3: 2}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := MarshalCanonical(map[canonicalDupKey]int{1: 1, 2: 2}); err == nil {
+		t.Error("expected an error for duplicate object keys")
+	}
+}
+
+type canonicalDupKey int
+
+func (canonicalDupKey) MarshalText() ([]byte, error) {
+	return []byte("dup"), nil
+}
+
+func TestCompareUTF16(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+		c int
+	}{
+		{"", "", 0},
+		{"", "a", -1},
+		{"a", "", 1},
+		{"a", "b", -1},
+		{"ab", "a", 1},
+		{"\ufb33", "😀", 1},
+		{"😀", "😁", -1},
+		{"€", "😀", -1},
+	}
+
+	for _, test := range tests {
+		if c := compareUTF16(test.a, test.b); c != test.c {
+			t.Errorf("compareUTF16(%q, %q): %d != %d", test.a, test.b, test.c, c)
+		}
+	}
+}
@@ -0,0 +1,664 @@
+// Package gen implements the generation of the methods written by the jutilgen
+// and jutil-gen commands.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/jutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Directive is the comment that selects the types returned by AnnotatedTypes.
+const Directive = "//jutil:generate"
+
+// Methods is a set of flags selecting the methods generated for each type.
+type Methods int
+
+const (
+	// LengthMethods generates the LengthJSONWithOptions and LengthJSON
+	// methods.
+	LengthMethods Methods = 1 << iota
+
+	// AppendMethods generates the AppendJSONWithOptions and AppendJSON
+	// methods.
+	AppendMethods
+
+	// EmptyMethods generates the IsEmptyJSON method.
+	EmptyMethods
+
+	// AllMethods generates all the methods.
+	AllMethods = LengthMethods | AppendMethods | EmptyMethods
+)
+
+// Load loads the syntax and type information of the packages matching the
+// patterns.
+func Load(patterns ...string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes,
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) != 0 {
+			return nil, pkg.Errors[0]
+		}
+	}
+
+	return pkgs, nil
+}
+
+// Generate returns the source of a file with the selected methods of the
+// struct types of pkg with the given names. The tool is the command mentioned
+// in the header of the file.
+func Generate(pkg *packages.Package, names []string, methods Methods, tool string) ([]byte, error) {
+	g := &generator{}
+
+	for _, name := range names {
+		obj := pkg.Types.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("%s: struct type not found", name)
+		}
+
+		t, ok := obj.Type().(*types.Named)
+		if !ok || t.TypeParams() != nil {
+			return nil, fmt.Errorf("%s: unsupported type", name)
+		}
+
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a struct type", name)
+		}
+
+		if hasMethod(types.NewPointer(t), "MarshalJSON") || hasMethod(types.NewPointer(t), "MarshalText") {
+			// The generated methods would take precedence over them.
+			return nil, fmt.Errorf("%s: the type has marshaling methods", name)
+		}
+
+		fields, err := structFields(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		// The methods which serialize the fields have a pointer receiver if
+		// some of them use pointer methods, which the json package only calls
+		// on addressable values.
+		g.recv, g.addr = name, false
+		for _, f := range fields {
+			if needsAddr(f.typ) {
+				g.recv, g.addr = "*"+name, true
+			}
+		}
+
+		if methods&LengthMethods != 0 {
+			g.length(fields)
+		}
+		if methods&AppendMethods != 0 {
+			g.append(fields)
+		}
+		if methods&EmptyMethods != 0 {
+			g.empty(name, fields)
+		}
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by %s; DO NOT EDIT.\n\n", tool)
+	fmt.Fprintf(&b, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(&b, "import (\n")
+	if g.strconv {
+		fmt.Fprintf(&b, "%q\n\n", "strconv")
+	}
+	fmt.Fprintf(&b, "%q\n", "github.com/segmentio/jutil")
+	fmt.Fprintf(&b, ")\n")
+	b.Write(g.buf.Bytes())
+
+	return format.Source(b.Bytes())
+}
+
+// AnnotatedTypes returns the names of the types of pkg which have the
+// jutil:generate directive in their documentation.
+func AnnotatedTypes(pkg *packages.Package) (names []string) {
+	for _, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range d.Specs {
+				s := spec.(*ast.TypeSpec)
+				doc := s.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				if hasDirective(doc) {
+					names = append(names, s.Name.Name)
+				}
+			}
+		}
+	}
+	return
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc != nil {
+		for _, c := range doc.List {
+			if strings.TrimSpace(c.Text) == Directive {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// field represents a struct field serialized to JSON.
+type field struct {
+	name      string // name of the field in the Go struct
+	key       string // name of the field in the JSON object
+	omitempty bool
+	omitzero  bool
+	typ       types.Type
+}
+
+// optional returns true if the field may be omitted from the JSON object.
+func (f field) optional() bool {
+	return f.omitempty || f.omitzero
+}
+
+// arg returns the expression passed to the jutil package to serialize the
+// value of the field, which is addressable if the generated methods have a
+// pointer receiver.
+func (f field) arg(addr bool) string {
+	if addr && needsAddr(f.typ) {
+		return "&v." + f.name
+	}
+	return "v." + f.name
+}
+
+// condition returns an expression that is true if the field has to be
+// written to the JSON object, assuming it is optional.
+func (f field) condition() string {
+	x := "v." + f.name
+
+	switch {
+	case f.omitempty && f.omitzero:
+		empty, zero := emptyExpr(x, f.typ, true), zeroExpr(x, f.typ, true)
+		if empty == zero {
+			return empty
+		}
+		return empty + " && " + zero
+	case f.omitzero:
+		return zeroExpr(x, f.typ, true)
+	}
+
+	return emptyExpr(x, f.typ, true)
+}
+
+// structFields returns the serialized fields of s, applying the same rules as
+// jutil.MakeStruct.
+func structFields(s *types.Struct) ([]field, error) {
+	fields := make([]field, 0, s.NumFields())
+
+	for i := 0; i != s.NumFields(); i++ {
+		f := s.Field(i)
+		t := jutil.ParseTag(reflect.StructTag(s.Tag(i)).Get("json"))
+
+		if t.Skip || (!f.Exported() && !f.Embedded()) {
+			continue
+		}
+
+		if !f.Exported() {
+			// The jutil package reports an error for these fields.
+			return nil, fmt.Errorf("%s: unsupported unexported embedded field", f.Name())
+		}
+
+		if len(t.Name) == 0 {
+			t.Name = f.Name()
+		}
+
+		fields = append(fields, field{
+			name:      f.Name(),
+			key:       t.Name,
+			omitempty: t.Omitempty,
+			omitzero:  t.Omitzero,
+			typ:       f.Type(),
+		})
+	}
+
+	return fields, nil
+}
+
+// The states of the fields written to a JSON object, which determine whether
+// a comma has to be written before the next field.
+const (
+	noFields = iota
+	someFields
+	maybeFields
+)
+
+type generator struct {
+	buf     bytes.Buffer
+	strconv bool
+
+	// The receiver of the methods of the current type, and whether it is a
+	// pointer.
+	recv string
+	addr bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) length(fields []field) {
+	g.printf("\n// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.\n")
+	g.printf("func (v %s) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {\n", g.recv)
+
+	if len(fields) == 0 {
+		g.printf("return 2, nil\n}\n")
+	} else {
+		g.printf("var c int\n")
+		state := noFields
+
+		for _, f := range fields {
+			if f.optional() {
+				g.printf("\nif %s {\n", f.condition())
+			} else {
+				g.printf("\n")
+			}
+
+			g.lengthValue(f)
+
+			comma := 0
+			switch state {
+			case someFields:
+				comma = 1
+			case maybeFields:
+				g.printf("if n != 0 {\nn++\n}\n")
+			}
+
+			if isPlainName(f.key) {
+				g.printf("n += %d + c\n", len(f.key)+3+comma)
+			} else {
+				g.printf("n += jutil.LengthString(%q, o) + %d + c\n", f.key, 1+comma)
+			}
+
+			if f.optional() {
+				g.printf("}\n")
+			}
+
+			state = nextState(state, f)
+		}
+
+		g.printf("\nreturn n + 2, nil\n}\n")
+	}
+
+	g.printf("\n// LengthJSON satisfies the jutil.Lengther interface, values that can't be\n")
+	g.printf("// serialized have a length of zero.\n")
+	g.printf("func (v %s) LengthJSON() int {\n", g.recv)
+	g.printf("n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)\n")
+	g.printf("return n\n}\n")
+}
+
+func (g *generator) lengthValue(f field) {
+	x, t := "v."+f.name, f.typ
+
+	switch kind := basicKind(t); kind {
+	case types.String:
+		g.printf("c = jutil.LengthString(%s, o)\n", convert(x, t, kind))
+	case types.Bool:
+		g.printf("c = jutil.LengthBool(%s)\n", convert(x, t, kind))
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		g.printf("c = jutil.LengthInt(%s)\n", convert(x, t, types.Int64))
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		g.printf("c = jutil.LengthUint(%s)\n", convert(x, t, types.Uint64))
+	case types.Float32:
+		g.printf("if c, err = jutil.LengthFloat(%s, 32, o); err != nil {\nreturn\n}\n", convert(x, t, types.Float64))
+	case types.Float64:
+		g.printf("if c, err = jutil.LengthFloat(%s, 64, o); err != nil {\nreturn\n}\n", convert(x, t, types.Float64))
+	default:
+		g.printf("if c, err = jutil.LengthWithOptions(%s, *o); err != nil {\nreturn\n}\n", f.arg(g.addr))
+	}
+}
+
+func (g *generator) append(fields []field) {
+	g.printf("\n// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.\n")
+
+	if len(fields) == 0 {
+		g.printf("func (v %s) AppendJSONWithOptions(b []byte, e *jutil.Encoder) ([]byte, error) {\n", g.recv)
+		g.printf("return append(b, '{', '}'), nil\n}\n")
+	} else {
+		g.printf("func (v %s) AppendJSONWithOptions(b []byte, e *jutil.Encoder) (_ []byte, err error) {\n", g.recv)
+		g.printf("b = append(b, '{')\n")
+
+		// The offset of the first field is needed to know whether a comma has
+		// to be written when fields may have been omitted before.
+		state := noFields
+
+		for _, f := range fields {
+			if state == maybeFields {
+				g.printf("n := len(b)\n")
+				break
+			}
+			state = nextState(state, f)
+		}
+
+		state = noFields
+
+		for _, f := range fields {
+			if f.optional() {
+				g.printf("\nif %s {\n", f.condition())
+			} else {
+				g.printf("\n")
+			}
+
+			comma := ""
+			switch state {
+			case someFields:
+				comma = ","
+			case maybeFields:
+				g.printf("if len(b) != n {\nb = append(b, ',')\n}\n")
+			}
+
+			key := (&jutil.Encoder{}).AppendString(nil, f.key)
+
+			if string(key) == string((&jutil.Encoder{EscapeHTML: true}).AppendString(nil, f.key)) {
+				g.printf("b = append(b, %s...)\n", quote(comma+string(key)+":"))
+			} else {
+				if len(comma) != 0 {
+					g.printf("b = append(b, ',')\n")
+				}
+				g.printf("b = e.AppendString(b, %q)\n", f.key)
+				g.printf("b = append(b, ':')\n")
+			}
+
+			g.appendValue(f)
+
+			if f.optional() {
+				g.printf("}\n")
+			}
+
+			state = nextState(state, f)
+		}
+
+		g.printf("\nreturn append(b, '}'), nil\n}\n")
+	}
+
+	g.printf("\n// AppendJSON satisfies the jutil.Appender interface, it writes v the way\n")
+	g.printf("// jutil.Append does and values that can't be serialized are written as null.\n")
+	g.printf("func (v %s) AppendJSON(b []byte) []byte {\n", g.recv)
+	g.printf("if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {\nreturn c\n}\n")
+	g.printf("return append(b, \"null\"...)\n}\n")
+}
+
+func (g *generator) appendValue(f field) {
+	x, t := "v."+f.name, f.typ
+
+	switch kind := basicKind(t); kind {
+	case types.String:
+		g.printf("b = e.AppendString(b, %s)\n", convert(x, t, kind))
+	case types.Bool:
+		g.strconv = true
+		g.printf("b = strconv.AppendBool(b, %s)\n", convert(x, t, kind))
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		g.strconv = true
+		g.printf("b = strconv.AppendInt(b, %s, 10)\n", convert(x, t, types.Int64))
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		g.strconv = true
+		g.printf("b = strconv.AppendUint(b, %s, 10)\n", convert(x, t, types.Uint64))
+	case types.Float32:
+		g.appendOrReturn(fmt.Sprintf("jutil.AppendFloat(b, %s, 32)", convert(x, t, types.Float64)))
+	case types.Float64:
+		g.appendOrReturn(fmt.Sprintf("jutil.AppendFloat(b, %s, 64)", convert(x, t, types.Float64)))
+	default:
+		g.appendOrReturn(fmt.Sprintf("e.Append(b, %s)", f.arg(g.addr)))
+	}
+}
+
+func (g *generator) appendOrReturn(call string) {
+	g.printf("if b, err = %s; err != nil {\nreturn b, err\n}\n", call)
+}
+
+func (g *generator) empty(name string, fields []field) {
+	g.printf("\n// IsEmptyJSON returns true if all the fields of v that are serialized to\n")
+	g.printf("// JSON have empty values.\n")
+	g.printf("func (v %s) IsEmptyJSON() bool {\n", name)
+
+	if len(fields) == 0 {
+		g.printf("return true\n}\n")
+		return
+	}
+
+	g.printf("return ")
+
+	for i, f := range fields {
+		if i != 0 {
+			g.printf(" &&\n")
+		}
+		g.printf("%s", emptyExpr("v."+f.name, f.typ, false))
+	}
+
+	g.printf("\n}\n")
+}
+
+func nextState(state int, f field) int {
+	switch {
+	case !f.optional():
+		return someFields
+	case state == noFields:
+		return maybeFields
+	}
+	return state
+}
+
+// basicKind returns the kind of t if values of this type can be serialized
+// without going through the jutil package, or types.Invalid otherwise.
+func basicKind(t types.Type) types.BasicKind {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok || hasJSONMethods(types.NewPointer(t)) || isJSONNumber(t) {
+		return types.Invalid
+	}
+
+	switch k := b.Kind(); k {
+	case types.Bool, types.String, types.Float32, types.Float64,
+		types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+		types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return k
+	}
+
+	return types.Invalid
+}
+
+// hasJSONMethods returns true if values of type t have methods that the jutil
+// package uses to serialize them. The method set of a pointer type includes
+// the methods of its element type.
+func hasJSONMethods(t types.Type) bool {
+	m := types.NewMethodSet(t)
+
+	for _, name := range []string{"LengthJSONWithOptions", "LengthJSON", "AppendJSONWithOptions", "AppendJSON", "MarshalJSON", "MarshalText"} {
+		if m.Lookup(nil, name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsAddr returns true if values of type t are serialized differently when
+// they are addressable, because the methods of their pointer type, or of the
+// pointer types of their fields or elements, are used.
+func needsAddr(t types.Type) bool {
+	if hasJSONMethods(t) {
+		return false
+	}
+	if hasJSONMethods(types.NewPointer(t)) {
+		return true
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i != u.NumFields(); i++ {
+			if needsAddr(u.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Array:
+		return needsAddr(u.Elem())
+	}
+
+	return false
+}
+
+func isJSONNumber(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "encoding/json" && obj.Name() == "Number"
+}
+
+// convert returns x converted to the basic type of the given kind, unless it
+// already has this type.
+func convert(x string, t types.Type, kind types.BasicKind) string {
+	if b := types.Typ[kind]; !types.Identical(t, b) {
+		return b.Name() + "(" + x + ")"
+	}
+	return x
+}
+
+// emptyExpr returns an expression that is true if x, of type t, is empty
+// according to jutil.IsEmptyValue, or not empty when negate is true.
+func emptyExpr(x string, t types.Type, negate bool) string {
+	var expr string
+	var op = compareOp(negate)
+
+	if hasMethod(t, "OmitEmptyJSON") {
+		return methodExpr(x, t, "OmitEmptyJSON", negate)
+	}
+
+	if hasMethod(types.NewPointer(t), "OmitEmptyJSON") {
+		// The method is only used on addressable values.
+		return callExpr("jutil.IsEmptyValue("+x+")", negate)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			expr = fmt.Sprintf("len(%s) %s 0", x, op)
+		case u.Info()&types.IsBoolean != 0:
+			expr = callExpr(x, !negate)
+		case u.Info()&(types.IsInteger|types.IsFloat) != 0:
+			expr = fmt.Sprintf("%s %s 0", x, op)
+		}
+	case *types.Pointer, *types.Interface:
+		expr = fmt.Sprintf("%s %s nil", x, op)
+	case *types.Slice, *types.Map, *types.Array:
+		expr = fmt.Sprintf("len(%s) %s 0", x, op)
+	}
+
+	if len(expr) == 0 {
+		expr = callExpr("jutil.IsEmptyValue("+x+")", negate)
+	}
+
+	return expr
+}
+
+// zeroExpr returns an expression that is true if x, of type t, is zero
+// according to jutil.IsZeroValue, or not zero when negate is true.
+func zeroExpr(x string, t types.Type, negate bool) string {
+	var op = compareOp(negate)
+
+	if hasMethod(t, "IsZero") {
+		return methodExpr(x, t, "IsZero", negate)
+	}
+
+	if hasMethod(types.NewPointer(t), "IsZero") {
+		return callExpr(x+".IsZero()", negate) // x is addressable
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return fmt.Sprintf("len(%s) %s 0", x, op)
+		case u.Info()&types.IsBoolean != 0:
+			return callExpr(x, !negate)
+		case u.Info()&(types.IsInteger|types.IsFloat) != 0:
+			return fmt.Sprintf("%s %s 0", x, op)
+		}
+	case *types.Pointer, *types.Interface, *types.Slice, *types.Map, *types.Chan, *types.Signature:
+		return fmt.Sprintf("%s %s nil", x, op)
+	}
+
+	return callExpr("jutil.IsZeroValue("+x+")", negate)
+}
+
+// methodExpr returns an expression calling the method of x with the given
+// name, which returns a boolean. Nil values are considered to return true.
+func methodExpr(x string, t types.Type, name string, negate bool) string {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		if negate {
+			return fmt.Sprintf("%s != nil && !%s.%s()", x, x, name)
+		}
+		return fmt.Sprintf("(%s == nil || %s.%s())", x, x, name)
+	}
+	return callExpr(x+"."+name+"()", negate)
+}
+
+func callExpr(call string, negate bool) string {
+	if negate {
+		return "!" + call
+	}
+	return call
+}
+
+func compareOp(negate bool) string {
+	if negate {
+		return "!="
+	}
+	return "=="
+}
+
+func hasMethod(t types.Type, name string) bool {
+	return types.NewMethodSet(t).Lookup(nil, name) != nil
+}
+
+// isPlainName returns true if s is represented the same way by all string
+// escaping options.
+func isPlainName(s string) bool {
+	for i := 0; i != len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '/', '<', '>', '&':
+			return false
+		default:
+			if c < 0x20 || c > 0x7e {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// quote returns a Go string literal representing s.
+func quote(s string) string {
+	if strings.IndexByte(s, '`') < 0 && strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
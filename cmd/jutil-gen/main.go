@@ -0,0 +1,80 @@
+// Command jutil-gen generates methods that compute the length of the JSON
+// representation of struct types without using reflection.
+//
+// It is intended to be used with go generate, for example:
+//
+//	//go:generate jutil-gen -type=Event,Tags
+//
+// For each type it writes a LengthJSONWithOptions method satisfying the
+// jutil.OptionsLengther interface, and a LengthJSON method satisfying the
+// jutil.Lengther interface. The methods are generated the same way as by the
+// jutilgen command, which selects the types with a comment instead of a flag
+// and also generates AppendJSONWithOptions, AppendJSON and IsEmptyJSON
+// methods.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/segmentio/jutil/cmd/internal/gen"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_length.go")
+	flag.Parse()
+
+	if len(*typeNames) == 0 {
+		fmt.Fprintln(os.Stderr, "jutil-gen: the -type flag is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if args := flag.Args(); len(args) != 0 {
+		dir = args[0]
+	}
+
+	types := strings.Split(*typeNames, ",")
+
+	src, err := generateDir(dir, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jutil-gen:", err)
+		os.Exit(1)
+	}
+
+	if len(*output) == 0 {
+		*output = filepath.Join(dir, strings.ToLower(types[0])+"_length.go")
+	}
+
+	if err = ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "jutil-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// generateDir loads the Go package in dir and returns the source of the
+// methods generated for types.
+func generateDir(dir string, types []string) ([]byte, error) {
+	if !filepath.IsAbs(dir) {
+		// Relative directories must start with a dot to not be confused
+		// with import paths.
+		dir = "./" + filepath.ToSlash(filepath.Clean(dir))
+	}
+
+	pkgs, err := gen.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s: expected one package but found %d", dir, len(pkgs))
+	}
+
+	return gen.Generate(pkgs[0], types, gen.LengthMethods, `"jutil-gen -type=`+strings.Join(types, ",")+`"`)
+}
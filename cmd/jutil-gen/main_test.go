@@ -0,0 +1,48 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	b, err := generateDir("testdata/event", []string{"Event", "Inner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := string(b)
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", b, 0); err != nil {
+		t.Fatalf("invalid generated code: %s\n%s", err, src)
+	}
+
+	for _, s := range []string{
+		`// Code generated by "jutil-gen -type=Event,Inner"; DO NOT EDIT.`,
+		`func (v Event) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {`,
+		`func (v Event) LengthJSON() int {`,
+		`func (v Inner) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {`,
+		`func (v Inner) LengthJSON() int {`,
+		`n += jutil.LengthString("<extra>", o) + 2 + c`,
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q in generated code:\n%s", s, src)
+		}
+	}
+
+	for _, s := range []string{"Ignored", "hidden", "AppendJSON", "IsEmptyJSON"} {
+		if strings.Contains(src, s) {
+			t.Errorf("unexpected %q in generated code:\n%s", s, src)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	for _, types := range [][]string{{"Missing"}, {"Event", "Missing"}, {"Other"}} {
+		if _, err := generateDir("testdata/event", types); err == nil {
+			t.Errorf("%v: expected an error", types)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package event
+
+type Event struct {
+	Name    string `json:"name"`
+	Level   int    `json:"level,omitempty"`
+	Score   float32
+	Tags    []string          `json:",omitempty"`
+	Extra   map[string]string `json:"<extra>"`
+	Ignored int               `json:"-"`
+	hidden  int
+	Inner
+}
+
+type Inner struct{}
+
+type Other int
@@ -0,0 +1,114 @@
+// Package example declares types used to test the code generated by jutilgen.
+package example
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//go:generate go run github.com/segmentio/jutil/cmd/jutilgen
+
+// Level is a named integer type without methods.
+type Level int8
+
+// Status is a named string type represented by its upper case value.
+type Status string
+
+// MarshalText satisfies the encoding.TextMarshaler interface.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(s))), nil
+}
+
+// Masked is a named string type with a MarshalText method on its pointer type,
+// which the json package only calls on addressable values.
+type Masked string
+
+// MarshalText satisfies the encoding.TextMarshaler interface.
+func (m *Masked) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", len(*m))), nil
+}
+
+// Event exercises the different kinds of fields supported by the generator.
+//
+//jutil:generate
+type Event struct {
+	ID       uint64            `json:"id"`
+	Name     string            `json:"name"`
+	Level    Level             `json:"level,omitempty"`
+	Status   Status            `json:"status"`
+	Score    float64           `json:"score,omitempty"`
+	Ratio    float32           `json:"ratio"`
+	OK       bool              `json:"ok,omitempty"`
+	Count    int               `json:",omitempty"`
+	Number   json.Number       `json:"number,omitempty"`
+	Time     time.Time         `json:"time"`
+	Elapsed  time.Duration     `json:"elapsed"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"<labels>,omitempty"`
+	Parent   *Event            `json:"parent,omitempty"`
+	Payload  interface{}       `json:"payload"`
+	Nested   Nested            `json:"nested,omitempty"`
+	Since    time.Time         `json:"since,omitzero"`
+	Window   [2]int            `json:"window,omitzero"`
+	Limit    *int              `json:"limit,omitempty,omitzero"`
+	Version  Version           `json:"version,omitzero"`
+	Previous *Version          `json:"previous,omitzero"`
+	Ignored  int               `json:"-"`
+	internal int
+	Meta
+}
+
+// Version is a struct type with an IsZero method.
+type Version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+}
+
+// IsZero returns true if the major version is zero.
+func (v Version) IsZero() bool {
+	return v.Major == 0
+}
+
+// Meta is embedded in Event, the jutil package doesn't flatten embedded
+// structs. It has no generated methods so they aren't promoted to the types
+// that embed it.
+type Meta struct {
+	Source string
+}
+
+// Nested is a struct type with only optional fields.
+//
+//jutil:generate
+type Nested struct {
+	A string `json:"a,omitempty"`
+	B []int  `json:"b,omitempty"`
+}
+
+type (
+	// Empty has no serialized fields.
+	//
+	//jutil:generate
+	Empty struct {
+		hidden int
+	}
+
+	// Leading starts with a field that is always written.
+	//
+	//jutil:generate
+	Leading struct {
+		X float32 `json:"x"`
+		Y Nested  `json:"y,omitempty"`
+		Z [2]bool `json:"z/z"`
+	}
+)
+
+// Account has a field which is serialized differently when it is addressable,
+// so the methods serializing it have a pointer receiver.
+//
+//jutil:generate
+type Account struct {
+	User     string    `json:"user"`
+	Password Masked    `json:"password"`
+	Previous [1]Masked `json:"previous,omitempty"`
+}
@@ -0,0 +1,306 @@
+package example
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/jutil"
+)
+
+// The plain types have the same fields as the annotated types but not their
+// generated methods, the jutil package uses reflection to serialize them.
+type (
+	plainEvent   Event
+	plainNested  Nested
+	plainEmpty   Empty
+	plainLeading Leading
+	plainAccount Account
+)
+
+// The encoders are paired with the options computing the length of their
+// output, the maps are written in random order by the encoders which don't
+// sort their keys so only the lengths of their outputs are compared.
+var encoders = []struct {
+	e jutil.Encoder
+	o jutil.LengthOptions
+}{
+	{jutil.Encoder{}, jutil.LengthOptions{}},
+	{jutil.Encoder{SortMapKeys: true}, jutil.LengthOptions{}},
+	{jutil.Encoder{EscapeHTML: true}, jutil.LengthOptions{EscapeHTML: true}},
+	{jutil.StdEncoder, jutil.StdLengthOptions},
+}
+
+var lengthOptions = []jutil.LengthOptions{
+	{},
+	jutil.StdLengthOptions,
+	jutil.LegacyLengthOptions,
+	jutil.EmptyCollectionsLengthOptions,
+}
+
+// generated is implemented by the types that have generated methods.
+type generated interface {
+	jutil.OptionsLengther
+	jutil.Lengther
+	jutil.OptionsAppender
+	jutil.Appender
+	IsEmptyJSON() bool
+}
+
+func TestGeneratedMethods(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	for i := 0; i != 1000; i++ {
+		e := randomEvent(r, 2)
+		l := Leading{X: float32(r.NormFloat64()), Y: randomNested(r), Z: [2]bool{r.Intn(2) == 0, true}}
+
+		testGenerated(t, e, plainEvent(e))
+		testGenerated(t, e.Nested, plainNested(e.Nested))
+		testGenerated(t, Nested{A: e.Source}, plainNested{A: e.Source})
+		testGenerated(t, l, plainLeading(l))
+
+		a := Account{User: randomString(r), Password: Masked(randomString(r))}
+		p := plainAccount(a)
+		testGenerated(t, &a, &p)
+	}
+
+	testGenerated(t, Event{}, plainEvent{})
+	testGenerated(t, Nested{}, plainNested{})
+	testGenerated(t, Empty{}, plainEmpty{})
+	testGenerated(t, Leading{}, plainLeading{})
+	testGenerated(t, &Account{}, &plainAccount{})
+}
+
+func testGenerated(t *testing.T, v generated, plain interface{}) {
+	expect, err := jutil.Marshal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := v.AppendJSON(nil); string(b) != string(expect) {
+		t.Errorf("invalid output:\n%s\n%s", expect, b)
+	}
+
+	for _, enc := range encoders {
+		expect, err := enc.e.Marshal(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := jutil.LengthWithOptions(v, enc.o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range []func() ([]byte, error){
+			func() ([]byte, error) { return v.AppendJSONWithOptions(nil, &enc.e) },
+			func() ([]byte, error) { return enc.e.Marshal(v) },
+		} {
+			b, err := f()
+			switch {
+			case err != nil:
+				t.Errorf("%+v: %v", enc.e, err)
+			case len(b) != len(expect) || len(b) != n:
+				t.Errorf("%+v: invalid output length: %d != %d != %d\n%s\n%s", enc.e, len(expect), len(b), n, expect, b)
+			case enc.e.SortMapKeys && string(b) != string(expect):
+				t.Errorf("%+v: invalid output:\n%s\n%s", enc.e, expect, b)
+			}
+		}
+	}
+
+	for _, opts := range lengthOptions {
+		n1, err1 := jutil.LengthWithOptions(plain, opts)
+		n2, err2 := v.LengthJSONWithOptions(&opts)
+
+		if (err1 != nil) != (err2 != nil) {
+			t.Errorf("%+v: error mismatch: %v != %v", opts, err1, err2)
+		} else if n1 != n2 {
+			t.Errorf("%+v: invalid length: %d != %d\n%s", opts, n1, n2, expect)
+		}
+	}
+
+	if n := v.LengthJSON(); n != mustLength(t, plain) {
+		t.Errorf("invalid length: %d != %d", mustLength(t, plain), n)
+	}
+
+	if empty := isEmptyStruct(plain); v.IsEmptyJSON() != empty {
+		t.Errorf("invalid emptiness of %s: %t", expect, !empty)
+	}
+}
+
+func mustLength(t *testing.T, v interface{}) int {
+	n, err := jutil.Length(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// isEmptyStruct returns true if all the fields of the struct v serialized by
+// the jutil package have empty values.
+func isEmptyStruct(v interface{}) bool {
+	r := reflect.Indirect(reflect.ValueOf(v))
+
+	for _, f := range jutil.LookupStruct(r.Type()) {
+		if !jutil.IsEmptyValue(r.FieldByIndex(f.Index).Interface()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TestGeneratedFields checks that the generated types don't change how the
+// fields holding them are serialized, by comparing with the standard json
+// package which ignores the generated methods.
+func TestGeneratedFields(t *testing.T) {
+	type wrapper struct {
+		N Nested   `json:"n,omitempty"`
+		E Empty    `json:"e,omitempty"`
+		L *Leading `json:"l,omitempty"`
+		P []Nested `json:"p,omitempty"`
+		A Account  `json:"a"`
+		Q *Account `json:"q,omitempty"`
+	}
+
+	r := rand.New(rand.NewSource(0))
+
+	tests := []interface{}{
+		wrapper{},
+		&wrapper{L: &Leading{}},
+		wrapper{N: randomNested(r), P: []Nested{{}, randomNested(r)}},
+		Leading{},
+		wrapper{A: Account{User: "<u>", Password: "secret"}},
+		&wrapper{A: Account{Password: "secret"}, Q: &Account{Previous: [1]Masked{"abc"}}},
+		[]Account{{Password: "secret"}},
+	}
+
+	for _, test := range tests {
+		expect, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b, err := jutil.Marshal(test); err != nil {
+			t.Error(err)
+		} else if string(b) != string(expect) {
+			t.Errorf("invalid output:\n%s\n%s", expect, b)
+		}
+
+		if n, err := jutil.LengthWithOptions(test, jutil.StdLengthOptions); err != nil {
+			t.Error(err)
+		} else if n != len(expect) {
+			t.Errorf("invalid length: %d != %d\n%s", len(expect), n, expect)
+		}
+
+		// The generated methods use the options of the encoder.
+		e := jutil.Encoder{}
+
+		if b, err := e.Marshal(test); err != nil {
+			t.Error(err)
+		} else if n, err := jutil.LengthWithOptions(test, jutil.LengthOptions{}); err != nil {
+			t.Error(err)
+		} else if n != len(b) {
+			t.Errorf("invalid length: %d != %d\n%s", len(b), n, b)
+		}
+	}
+}
+
+func TestGeneratedEncoderOptions(t *testing.T) {
+	e := jutil.Encoder{}
+
+	if b, err := e.Marshal(Nested{A: "<a>"}); err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `{"a":"<a>"}` {
+		t.Error("invalid output:", s)
+	}
+
+	if b, err := e.Marshal(Event{Labels: map[string]string{"&": "&"}}); err != nil {
+		t.Error(err)
+	} else if !strings.Contains(string(b), `"<labels>":{"&":"&"}`) {
+		t.Error("invalid output:", string(b))
+	}
+}
+
+func TestGeneratedErrors(t *testing.T) {
+	e := Event{Ratio: float32(math.Inf(1))}
+
+	if _, err := e.LengthJSONWithOptions(&jutil.LengthOptions{}); err == nil {
+		t.Error("expected an error for an infinite float")
+	}
+
+	if b := e.AppendJSON(nil); string(b) != "null" {
+		t.Errorf("invalid output: %s", b)
+	}
+
+	if _, err := e.AppendJSONWithOptions(nil, &jutil.Encoder{}); err == nil {
+		t.Error("expected an error for an infinite float")
+	}
+
+	if _, err := jutil.Marshal([]Event{e}); err == nil {
+		t.Error("expected the error to be returned by the encoder")
+	}
+}
+
+func randomEvent(r *rand.Rand, depth int) Event {
+	e := Event{
+		ID:      r.Uint64() >> uint(r.Intn(64)),
+		Name:    randomString(r),
+		Level:   Level(r.Intn(256) - 128),
+		Status:  Status(randomString(r)),
+		Ratio:   float32(r.ExpFloat64()),
+		OK:      r.Intn(2) == 0,
+		Elapsed: time.Duration(r.Int63n(int64(time.Hour))),
+		Nested:  randomNested(r),
+		Meta:    Meta{Source: randomString(r)},
+	}
+
+	if r.Intn(2) == 0 {
+		e.Score = r.NormFloat64() * math.Pow(10, float64(r.Intn(60)-30))
+		e.Count = r.Int()
+		e.Number = json.Number("1.5e3")
+		e.Time = time.Unix(r.Int63n(1<<32), r.Int63n(1e9)).UTC()
+		e.Tags = []string{randomString(r), randomString(r)}
+		e.Labels = map[string]string{randomString(r): randomString(r), "b": randomString(r)}
+		e.Payload = map[string]interface{}{"x": []interface{}{1.5, "<>", nil}}
+	}
+
+	if r.Intn(2) == 0 {
+		limit := r.Intn(2)
+		e.Since = time.Unix(r.Int63n(1<<32), 0).UTC()
+		e.Window = [2]int{0, r.Intn(2)}
+		e.Limit = &limit
+		e.Version = Version{Major: r.Intn(2), Minor: 1}
+		e.Previous = &Version{Major: r.Intn(2)}
+	}
+
+	if depth != 0 && r.Intn(2) == 0 {
+		p := randomEvent(r, depth-1)
+		e.Parent = &p
+	}
+
+	return e
+}
+
+func randomNested(r *rand.Rand) (n Nested) {
+	if r.Intn(2) == 0 {
+		n.A = randomString(r)
+	}
+	if r.Intn(2) == 0 {
+		n.B = []int{r.Int(), -r.Int()}
+	}
+	return
+}
+
+func randomString(r *rand.Rand) string {
+	const chars = "abc \"\\/<>&\n\t\x01é \xff"
+	b := make([]byte, r.Intn(8))
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
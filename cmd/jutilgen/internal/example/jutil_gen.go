@@ -0,0 +1,560 @@
+// Code generated by jutilgen; DO NOT EDIT.
+
+package example
+
+import (
+	"strconv"
+
+	"github.com/segmentio/jutil"
+)
+
+// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.
+func (v Event) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {
+	var c int
+
+	c = jutil.LengthUint(v.ID)
+	n += 5 + c
+
+	c = jutil.LengthString(v.Name, o)
+	n += 8 + c
+
+	if v.Level != 0 {
+		c = jutil.LengthInt(int64(v.Level))
+		n += 9 + c
+	}
+
+	if c, err = jutil.LengthWithOptions(v.Status, *o); err != nil {
+		return
+	}
+	n += 10 + c
+
+	if v.Score != 0 {
+		if c, err = jutil.LengthFloat(v.Score, 64, o); err != nil {
+			return
+		}
+		n += 9 + c
+	}
+
+	if c, err = jutil.LengthFloat(float64(v.Ratio), 32, o); err != nil {
+		return
+	}
+	n += 9 + c
+
+	if v.OK {
+		c = jutil.LengthBool(v.OK)
+		n += 6 + c
+	}
+
+	if v.Count != 0 {
+		c = jutil.LengthInt(int64(v.Count))
+		n += 9 + c
+	}
+
+	if len(v.Number) != 0 {
+		if c, err = jutil.LengthWithOptions(v.Number, *o); err != nil {
+			return
+		}
+		n += 10 + c
+	}
+
+	if c, err = jutil.LengthWithOptions(v.Time, *o); err != nil {
+		return
+	}
+	n += 8 + c
+
+	c = jutil.LengthInt(int64(v.Elapsed))
+	n += 11 + c
+
+	if len(v.Tags) != 0 {
+		if c, err = jutil.LengthWithOptions(v.Tags, *o); err != nil {
+			return
+		}
+		n += 8 + c
+	}
+
+	if len(v.Labels) != 0 {
+		if c, err = jutil.LengthWithOptions(v.Labels, *o); err != nil {
+			return
+		}
+		n += jutil.LengthString("<labels>", o) + 2 + c
+	}
+
+	if v.Parent != nil {
+		if c, err = jutil.LengthWithOptions(v.Parent, *o); err != nil {
+			return
+		}
+		n += 10 + c
+	}
+
+	if c, err = jutil.LengthWithOptions(v.Payload, *o); err != nil {
+		return
+	}
+	n += 11 + c
+
+	if !jutil.IsEmptyValue(v.Nested) {
+		if c, err = jutil.LengthWithOptions(v.Nested, *o); err != nil {
+			return
+		}
+		n += 10 + c
+	}
+
+	if !v.Since.IsZero() {
+		if c, err = jutil.LengthWithOptions(v.Since, *o); err != nil {
+			return
+		}
+		n += 9 + c
+	}
+
+	if !jutil.IsZeroValue(v.Window) {
+		if c, err = jutil.LengthWithOptions(v.Window, *o); err != nil {
+			return
+		}
+		n += 10 + c
+	}
+
+	if v.Limit != nil {
+		if c, err = jutil.LengthWithOptions(v.Limit, *o); err != nil {
+			return
+		}
+		n += 9 + c
+	}
+
+	if !v.Version.IsZero() {
+		if c, err = jutil.LengthWithOptions(v.Version, *o); err != nil {
+			return
+		}
+		n += 11 + c
+	}
+
+	if v.Previous != nil && !v.Previous.IsZero() {
+		if c, err = jutil.LengthWithOptions(v.Previous, *o); err != nil {
+			return
+		}
+		n += 12 + c
+	}
+
+	if c, err = jutil.LengthWithOptions(v.Meta, *o); err != nil {
+		return
+	}
+	n += 8 + c
+
+	return n + 2, nil
+}
+
+// LengthJSON satisfies the jutil.Lengther interface, values that can't be
+// serialized have a length of zero.
+func (v Event) LengthJSON() int {
+	n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)
+	return n
+}
+
+// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.
+func (v Event) AppendJSONWithOptions(b []byte, e *jutil.Encoder) (_ []byte, err error) {
+	b = append(b, '{')
+
+	b = append(b, `"id":`...)
+	b = strconv.AppendUint(b, v.ID, 10)
+
+	b = append(b, `,"name":`...)
+	b = e.AppendString(b, v.Name)
+
+	if v.Level != 0 {
+		b = append(b, `,"level":`...)
+		b = strconv.AppendInt(b, int64(v.Level), 10)
+	}
+
+	b = append(b, `,"status":`...)
+	if b, err = e.Append(b, v.Status); err != nil {
+		return b, err
+	}
+
+	if v.Score != 0 {
+		b = append(b, `,"score":`...)
+		if b, err = jutil.AppendFloat(b, v.Score, 64); err != nil {
+			return b, err
+		}
+	}
+
+	b = append(b, `,"ratio":`...)
+	if b, err = jutil.AppendFloat(b, float64(v.Ratio), 32); err != nil {
+		return b, err
+	}
+
+	if v.OK {
+		b = append(b, `,"ok":`...)
+		b = strconv.AppendBool(b, v.OK)
+	}
+
+	if v.Count != 0 {
+		b = append(b, `,"Count":`...)
+		b = strconv.AppendInt(b, int64(v.Count), 10)
+	}
+
+	if len(v.Number) != 0 {
+		b = append(b, `,"number":`...)
+		if b, err = e.Append(b, v.Number); err != nil {
+			return b, err
+		}
+	}
+
+	b = append(b, `,"time":`...)
+	if b, err = e.Append(b, v.Time); err != nil {
+		return b, err
+	}
+
+	b = append(b, `,"elapsed":`...)
+	b = strconv.AppendInt(b, int64(v.Elapsed), 10)
+
+	if len(v.Tags) != 0 {
+		b = append(b, `,"tags":`...)
+		if b, err = e.Append(b, v.Tags); err != nil {
+			return b, err
+		}
+	}
+
+	if len(v.Labels) != 0 {
+		b = append(b, ',')
+		b = e.AppendString(b, "<labels>")
+		b = append(b, ':')
+		if b, err = e.Append(b, v.Labels); err != nil {
+			return b, err
+		}
+	}
+
+	if v.Parent != nil {
+		b = append(b, `,"parent":`...)
+		if b, err = e.Append(b, v.Parent); err != nil {
+			return b, err
+		}
+	}
+
+	b = append(b, `,"payload":`...)
+	if b, err = e.Append(b, v.Payload); err != nil {
+		return b, err
+	}
+
+	if !jutil.IsEmptyValue(v.Nested) {
+		b = append(b, `,"nested":`...)
+		if b, err = e.Append(b, v.Nested); err != nil {
+			return b, err
+		}
+	}
+
+	if !v.Since.IsZero() {
+		b = append(b, `,"since":`...)
+		if b, err = e.Append(b, v.Since); err != nil {
+			return b, err
+		}
+	}
+
+	if !jutil.IsZeroValue(v.Window) {
+		b = append(b, `,"window":`...)
+		if b, err = e.Append(b, v.Window); err != nil {
+			return b, err
+		}
+	}
+
+	if v.Limit != nil {
+		b = append(b, `,"limit":`...)
+		if b, err = e.Append(b, v.Limit); err != nil {
+			return b, err
+		}
+	}
+
+	if !v.Version.IsZero() {
+		b = append(b, `,"version":`...)
+		if b, err = e.Append(b, v.Version); err != nil {
+			return b, err
+		}
+	}
+
+	if v.Previous != nil && !v.Previous.IsZero() {
+		b = append(b, `,"previous":`...)
+		if b, err = e.Append(b, v.Previous); err != nil {
+			return b, err
+		}
+	}
+
+	b = append(b, `,"Meta":`...)
+	if b, err = e.Append(b, v.Meta); err != nil {
+		return b, err
+	}
+
+	return append(b, '}'), nil
+}
+
+// AppendJSON satisfies the jutil.Appender interface, it writes v the way
+// jutil.Append does and values that can't be serialized are written as null.
+func (v Event) AppendJSON(b []byte) []byte {
+	if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {
+		return c
+	}
+	return append(b, "null"...)
+}
+
+// IsEmptyJSON returns true if all the fields of v that are serialized to
+// JSON have empty values.
+func (v Event) IsEmptyJSON() bool {
+	return v.ID == 0 &&
+		len(v.Name) == 0 &&
+		v.Level == 0 &&
+		len(v.Status) == 0 &&
+		v.Score == 0 &&
+		v.Ratio == 0 &&
+		!v.OK &&
+		v.Count == 0 &&
+		len(v.Number) == 0 &&
+		jutil.IsEmptyValue(v.Time) &&
+		v.Elapsed == 0 &&
+		len(v.Tags) == 0 &&
+		len(v.Labels) == 0 &&
+		v.Parent == nil &&
+		v.Payload == nil &&
+		jutil.IsEmptyValue(v.Nested) &&
+		jutil.IsEmptyValue(v.Since) &&
+		len(v.Window) == 0 &&
+		v.Limit == nil &&
+		jutil.IsEmptyValue(v.Version) &&
+		v.Previous == nil &&
+		jutil.IsEmptyValue(v.Meta)
+}
+
+// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.
+func (v Nested) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {
+	var c int
+
+	if len(v.A) != 0 {
+		c = jutil.LengthString(v.A, o)
+		n += 4 + c
+	}
+
+	if len(v.B) != 0 {
+		if c, err = jutil.LengthWithOptions(v.B, *o); err != nil {
+			return
+		}
+		if n != 0 {
+			n++
+		}
+		n += 4 + c
+	}
+
+	return n + 2, nil
+}
+
+// LengthJSON satisfies the jutil.Lengther interface, values that can't be
+// serialized have a length of zero.
+func (v Nested) LengthJSON() int {
+	n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)
+	return n
+}
+
+// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.
+func (v Nested) AppendJSONWithOptions(b []byte, e *jutil.Encoder) (_ []byte, err error) {
+	b = append(b, '{')
+	n := len(b)
+
+	if len(v.A) != 0 {
+		b = append(b, `"a":`...)
+		b = e.AppendString(b, v.A)
+	}
+
+	if len(v.B) != 0 {
+		if len(b) != n {
+			b = append(b, ',')
+		}
+		b = append(b, `"b":`...)
+		if b, err = e.Append(b, v.B); err != nil {
+			return b, err
+		}
+	}
+
+	return append(b, '}'), nil
+}
+
+// AppendJSON satisfies the jutil.Appender interface, it writes v the way
+// jutil.Append does and values that can't be serialized are written as null.
+func (v Nested) AppendJSON(b []byte) []byte {
+	if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {
+		return c
+	}
+	return append(b, "null"...)
+}
+
+// IsEmptyJSON returns true if all the fields of v that are serialized to
+// JSON have empty values.
+func (v Nested) IsEmptyJSON() bool {
+	return len(v.A) == 0 &&
+		len(v.B) == 0
+}
+
+// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.
+func (v Empty) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {
+	return 2, nil
+}
+
+// LengthJSON satisfies the jutil.Lengther interface, values that can't be
+// serialized have a length of zero.
+func (v Empty) LengthJSON() int {
+	n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)
+	return n
+}
+
+// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.
+func (v Empty) AppendJSONWithOptions(b []byte, e *jutil.Encoder) ([]byte, error) {
+	return append(b, '{', '}'), nil
+}
+
+// AppendJSON satisfies the jutil.Appender interface, it writes v the way
+// jutil.Append does and values that can't be serialized are written as null.
+func (v Empty) AppendJSON(b []byte) []byte {
+	if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {
+		return c
+	}
+	return append(b, "null"...)
+}
+
+// IsEmptyJSON returns true if all the fields of v that are serialized to
+// JSON have empty values.
+func (v Empty) IsEmptyJSON() bool {
+	return true
+}
+
+// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.
+func (v Leading) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {
+	var c int
+
+	if c, err = jutil.LengthFloat(float64(v.X), 32, o); err != nil {
+		return
+	}
+	n += 4 + c
+
+	if !jutil.IsEmptyValue(v.Y) {
+		if c, err = jutil.LengthWithOptions(v.Y, *o); err != nil {
+			return
+		}
+		n += 5 + c
+	}
+
+	if c, err = jutil.LengthWithOptions(v.Z, *o); err != nil {
+		return
+	}
+	n += jutil.LengthString("z/z", o) + 2 + c
+
+	return n + 2, nil
+}
+
+// LengthJSON satisfies the jutil.Lengther interface, values that can't be
+// serialized have a length of zero.
+func (v Leading) LengthJSON() int {
+	n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)
+	return n
+}
+
+// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.
+func (v Leading) AppendJSONWithOptions(b []byte, e *jutil.Encoder) (_ []byte, err error) {
+	b = append(b, '{')
+
+	b = append(b, `"x":`...)
+	if b, err = jutil.AppendFloat(b, float64(v.X), 32); err != nil {
+		return b, err
+	}
+
+	if !jutil.IsEmptyValue(v.Y) {
+		b = append(b, `,"y":`...)
+		if b, err = e.Append(b, v.Y); err != nil {
+			return b, err
+		}
+	}
+
+	b = append(b, `,"z/z":`...)
+	if b, err = e.Append(b, v.Z); err != nil {
+		return b, err
+	}
+
+	return append(b, '}'), nil
+}
+
+// AppendJSON satisfies the jutil.Appender interface, it writes v the way
+// jutil.Append does and values that can't be serialized are written as null.
+func (v Leading) AppendJSON(b []byte) []byte {
+	if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {
+		return c
+	}
+	return append(b, "null"...)
+}
+
+// IsEmptyJSON returns true if all the fields of v that are serialized to
+// JSON have empty values.
+func (v Leading) IsEmptyJSON() bool {
+	return v.X == 0 &&
+		jutil.IsEmptyValue(v.Y) &&
+		len(v.Z) == 0
+}
+
+// LengthJSONWithOptions satisfies the jutil.OptionsLengther interface.
+func (v *Account) LengthJSONWithOptions(o *jutil.LengthOptions) (n int, err error) {
+	var c int
+
+	c = jutil.LengthString(v.User, o)
+	n += 7 + c
+
+	if c, err = jutil.LengthWithOptions(&v.Password, *o); err != nil {
+		return
+	}
+	n += 12 + c
+
+	if len(v.Previous) != 0 {
+		if c, err = jutil.LengthWithOptions(&v.Previous, *o); err != nil {
+			return
+		}
+		n += 12 + c
+	}
+
+	return n + 2, nil
+}
+
+// LengthJSON satisfies the jutil.Lengther interface, values that can't be
+// serialized have a length of zero.
+func (v *Account) LengthJSON() int {
+	n, _ := v.LengthJSONWithOptions(&jutil.LegacyLengthOptions)
+	return n
+}
+
+// AppendJSONWithOptions satisfies the jutil.OptionsAppender interface.
+func (v *Account) AppendJSONWithOptions(b []byte, e *jutil.Encoder) (_ []byte, err error) {
+	b = append(b, '{')
+
+	b = append(b, `"user":`...)
+	b = e.AppendString(b, v.User)
+
+	b = append(b, `,"password":`...)
+	if b, err = e.Append(b, &v.Password); err != nil {
+		return b, err
+	}
+
+	if len(v.Previous) != 0 {
+		b = append(b, `,"previous":`...)
+		if b, err = e.Append(b, &v.Previous); err != nil {
+			return b, err
+		}
+	}
+
+	return append(b, '}'), nil
+}
+
+// AppendJSON satisfies the jutil.Appender interface, it writes v the way
+// jutil.Append does and values that can't be serialized are written as null.
+func (v *Account) AppendJSON(b []byte) []byte {
+	if c, err := v.AppendJSONWithOptions(b, &jutil.StdEncoder); err == nil {
+		return c
+	}
+	return append(b, "null"...)
+}
+
+// IsEmptyJSON returns true if all the fields of v that are serialized to
+// JSON have empty values.
+func (v Account) IsEmptyJSON() bool {
+	return len(v.User) == 0 &&
+		len(v.Password) == 0 &&
+		len(v.Previous) == 0
+}
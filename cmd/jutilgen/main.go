@@ -0,0 +1,88 @@
+// Command jutilgen generates methods that serialize struct types to JSON
+// without using reflection.
+//
+// It is intended to be used with go generate, the struct types to generate
+// methods for are selected by adding a jutil:generate comment to their
+// declaration:
+//
+//	//go:generate jutilgen
+//
+//	//jutil:generate
+//	type Event struct {
+//		...
+//	}
+//
+// For each type it writes LengthJSONWithOptions and LengthJSON methods
+// satisfying the jutil.OptionsLengther and jutil.Lengther interfaces,
+// AppendJSONWithOptions and AppendJSON methods satisfying the
+// jutil.OptionsAppender and jutil.Appender interfaces and an IsEmptyJSON
+// method. The generated methods follow the same rules as the reflection-based
+// algorithms of the jutil package, the field names and the omitempty and
+// omitzero flags are obtained with jutil.ParseTag the same way
+// jutil.MakeStructField does. The jutil.Encoder type calls
+// AppendJSONWithOptions so the output follows its options, AppendJSON writes
+// values the way jutil.Append does.
+//
+// The methods have a pointer receiver when fields of the type have marshaling
+// methods on their pointer type, which are only used on addressable values.
+// Types that have their own MarshalJSON or MarshalText methods are rejected.
+//
+// IsEmptyJSON doesn't satisfy the jutil.Emptier interface, so the struct fields
+// holding the generated types are never omitted by omitempty, like in the
+// standard json package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/segmentio/jutil/cmd/internal/gen"
+	"golang.org/x/tools/go/packages"
+)
+
+func main() {
+	output := flag.String("output", "jutil_gen.go", "name of the file generated in each package directory")
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	pkgs, err := gen.Load(patterns...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jutilgen:", err)
+		os.Exit(1)
+	}
+
+	for _, pkg := range pkgs {
+		src, err := generate(pkg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jutilgen: %s: %s\n", pkg.PkgPath, err)
+			os.Exit(1)
+		}
+		if src == nil {
+			continue // no annotated types
+		}
+
+		path := filepath.Join(filepath.Dir(pkg.GoFiles[0]), *output)
+
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "jutilgen:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// generate returns the source of a file with the methods of the annotated
+// struct types of pkg, or nil if the package has no annotated types.
+func generate(pkg *packages.Package) ([]byte, error) {
+	names := gen.AnnotatedTypes(pkg)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return gen.Generate(pkg, names, gen.AllMethods, "jutilgen")
+}
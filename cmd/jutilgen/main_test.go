@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/segmentio/jutil/cmd/internal/gen"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	const golden = "internal/example/jutil_gen.go"
+
+	pkgs, err := gen.Load("./internal/example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := generate(pkgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := ioutil.WriteFile(golden, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, expect) {
+		t.Errorf("the generated code doesn't match %s, run the tests with -update to regenerate it:\n%s", golden, b)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	pkgs, err := gen.Load("./testdata/invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := generate(pkgs[0]); err == nil {
+		t.Error("expected an error for a type which isn't a struct")
+	}
+}
+
+func TestGenerateMarshalerError(t *testing.T) {
+	pkgs, err := gen.Load("./testdata/marshaler")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := generate(pkgs[0]); err == nil {
+		t.Error("expected an error for a type which has a MarshalJSON method")
+	}
+}
+
+func TestAnnotatedTypesNone(t *testing.T) {
+	pkgs, err := gen.Load(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := generate(pkgs[0]); err != nil || b != nil {
+		t.Errorf("unexpected output for a package without annotated types: %q %v", b, err)
+	}
+}
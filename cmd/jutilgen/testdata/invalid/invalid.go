@@ -0,0 +1,4 @@
+package invalid
+
+//jutil:generate
+type Invalid []int
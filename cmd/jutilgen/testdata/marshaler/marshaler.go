@@ -0,0 +1,10 @@
+package marshaler
+
+//jutil:generate
+type Marshaler struct {
+	A int
+}
+
+func (m *Marshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"marshaler"`), nil
+}
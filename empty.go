@@ -2,13 +2,115 @@ package jutil
 
 import "reflect"
 
+// Emptier can be implemented by a value to override the rules that determine
+// whether it is empty, and therefore not serialized if `omitempty` is set on a
+// struct field with this value.
+//
+// The method has a dedicated name so that types don't implement the interface
+// by accident, the IsEmptyJSON methods generated by jutilgen don't change how
+// the fields holding those types are serialized.
+type Emptier interface {
+	// OmitEmptyJSON returns true if the value is empty.
+	OmitEmptyJSON() bool
+}
+
+// Zeroer is the interface of values that have an IsZero method, which is used
+// to determine whether they are zero when `omitzero` is set on a struct field
+// with this value.
+type Zeroer interface {
+	IsZero() bool
+}
+
+var (
+	emptierType = reflect.TypeOf((*Emptier)(nil)).Elem()
+	zeroerType  = reflect.TypeOf((*Zeroer)(nil)).Elem()
+)
+
 // IsEmptyValue returns true if the value given as argument would be considered
 // empty by the standard json package, and therefore not serialized if
 // `omitempty` is set on a struct field with this value.
+//
+// Values that implement the Emptier interface are empty if their OmitEmptyJSON
+// method returns true.
 func IsEmptyValue(v interface{}) bool {
-	return isEmptyValue(reflect.ValueOf(v))
+	return isEmptyField(reflect.ValueOf(v))
+}
+
+// IsZeroValue returns true if the value given as argument is zero, and
+// therefore not serialized if `omitzero` is set on a struct field with this
+// value.
+//
+// Like the standard json package, the IsZero method of the value is used when
+// it has one, otherwise the value is zero if all its bytes are zero, which
+// means that structs and arrays are zero when all their fields or elements
+// are.
+func IsZeroValue(v interface{}) bool {
+	return isZeroValue(reflect.ValueOf(v))
+}
+
+// isEmptyField is like isEmptyValue but takes the Emptier interface into
+// account.
+func isEmptyField(v reflect.Value) bool {
+	if !v.IsValid() {
+		return isEmptyValue(v)
+	}
+	return emptyFunc(v.Type())(v)
+}
+
+// emptyFunc returns a function that tells whether values of type t are empty,
+// specialized for the type to avoid the checks done by isEmptyField on each
+// call.
+func emptyFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Implements(emptierType):
+		return isEmptyEmptier
+	case reflect.PtrTo(t).Implements(emptierType):
+		return isEmptyAddrEmptier
+	}
+
+	switch t.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return isEmptyLen
+	case reflect.Bool:
+		return isEmptyBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return isEmptyInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return isEmptyUint
+	case reflect.Float32, reflect.Float64:
+		return isEmptyFloat
+	case reflect.Interface, reflect.Ptr:
+		return isEmptyNil
+	}
+
+	return isNeverEmpty
+}
+
+func isEmptyEmptier(v reflect.Value) bool {
+	switch {
+	case !v.CanInterface():
+		return isEmptyValue(v)
+	case (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil():
+		return true
+	}
+	return v.Interface().(Emptier).OmitEmptyJSON()
+}
+
+func isEmptyAddrEmptier(v reflect.Value) bool {
+	if !v.CanAddr() || !v.CanInterface() {
+		return isEmptyValue(v)
+	}
+	return v.Addr().Interface().(Emptier).OmitEmptyJSON()
 }
 
+func isEmptyLen(v reflect.Value) bool   { return v.Len() == 0 }
+func isEmptyBool(v reflect.Value) bool  { return !v.Bool() }
+func isEmptyInt(v reflect.Value) bool   { return v.Int() == 0 }
+func isEmptyUint(v reflect.Value) bool  { return v.Uint() == 0 }
+func isEmptyFloat(v reflect.Value) bool { return v.Float() == 0 }
+func isEmptyNil(v reflect.Value) bool   { return v.IsNil() }
+func isNeverEmpty(reflect.Value) bool   { return false }
+
 // Copied from https://golang.org/src/encoding/json/encode.go?h=isEmpty#L282
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
@@ -27,3 +129,60 @@ func isEmptyValue(v reflect.Value) bool {
 	}
 	return false
 }
+
+// isZeroValue follows the rules of the omitzero option in
+// https://golang.org/src/encoding/json/encode.go?h=isZero
+func isZeroValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	return zeroFunc(v.Type())(v)
+}
+
+// zeroFunc returns a function that tells whether values of type t are zero,
+// specialized for the type like emptyFunc.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Implements(zeroerType):
+		return isZeroZeroer
+	case reflect.PtrTo(t).Implements(zeroerType):
+		return isZeroAddrZeroer
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return isEmptyLen
+	case reflect.Bool:
+		return isEmptyBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return isEmptyInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return isEmptyUint
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return isEmptyNil
+	}
+
+	return reflect.Value.IsZero
+}
+
+func isZeroZeroer(v reflect.Value) bool {
+	switch {
+	case !v.CanInterface():
+		return v.IsZero()
+	case (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil():
+		return true
+	}
+	return v.Interface().(Zeroer).IsZero()
+}
+
+func isZeroAddrZeroer(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return v.IsZero()
+	}
+	if !v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	return v.Addr().Interface().(Zeroer).IsZero()
+}
@@ -1,6 +1,11 @@
 package jutil
 
-import "testing"
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestIsEmptyTrue(t *testing.T) {
 	tests := []interface{}{
@@ -41,3 +46,141 @@ func TestIsEmptyFalse(t *testing.T) {
 		}
 	}
 }
+
+type emptier struct{ empty bool }
+
+func (e emptier) OmitEmptyJSON() bool { return e.empty }
+
+type ptrEmptier struct{ n int }
+
+func (e *ptrEmptier) OmitEmptyJSON() bool { return e.n == 0 }
+
+func TestIsEmptyEmptier(t *testing.T) {
+	if !IsEmptyValue(emptier{empty: true}) {
+		t.Error("emptier should be empty")
+	}
+
+	if IsEmptyValue(emptier{}) {
+		t.Error("emptier should not be empty")
+	}
+
+	if !IsEmptyValue((*emptier)(nil)) {
+		t.Error("nil emptier should be empty")
+	}
+
+	v := struct{ E ptrEmptier }{}
+	if !isEmptyField(reflect.ValueOf(&v).Elem().Field(0)) {
+		t.Error("addressable emptier should be empty")
+	}
+}
+
+type zeroer struct{ n int }
+
+func (z zeroer) IsZero() bool { return z.n < 0 }
+
+type ptrZeroer struct{ n int }
+
+func (z *ptrZeroer) IsZero() bool { return z.n < 0 }
+
+func TestIsZeroValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		zero  bool
+	}{
+		{nil, true},
+		{0, true},
+		{1, false},
+		{"", true},
+		{[]int(nil), true},
+		{[]int{}, false},
+		{map[string]int(nil), true},
+		{map[string]int{}, false},
+		{struct{}{}, true},
+		{struct{ A, B int }{}, true},
+		{struct{ A, B int }{B: 1}, false},
+		{struct{ A *int }{}, true},
+		{[2]int{}, true},
+		{[2]int{0, 1}, false},
+		{time.Time{}, true},
+		{time.Time{}.In(time.FixedZone("", 3600)), true},
+		{time.Unix(0, 0), false},
+		{zeroer{}, false},
+		{zeroer{n: -1}, true},
+		{(*zeroer)(nil), true},
+		{&zeroer{}, false},
+		{ptrZeroer{}, false},
+		{ptrZeroer{n: -1}, true},
+	}
+
+	for _, test := range tests {
+		if zero := IsZeroValue(test.value); zero != test.zero {
+			t.Errorf("%#v: invalid zero-ness: %t", test.value, zero)
+		}
+	}
+}
+
+func TestOmitzero(t *testing.T) {
+	type T struct {
+		A int             `json:"a,omitzero"`
+		B []int           `json:"b,omitzero"`
+		C time.Time       `json:"c,omitzero"`
+		D struct{ X int } `json:"d,omitzero"`
+		E zeroer          `json:"e,omitzero"`
+		F *zeroer         `json:"f,omitzero"`
+		G string          `json:"g,omitempty,omitzero"`
+	}
+
+	tests := []T{
+		{},
+		{B: []int{}, E: zeroer{n: -1}, F: &zeroer{n: -1}},
+		{A: 1, C: time.Unix(1, 0).UTC(), G: "g"},
+		{D: struct{ X int }{1}, E: zeroer{n: 1}, F: &zeroer{}},
+	}
+
+	for _, test := range tests {
+		expect, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b, err := Marshal(test); err != nil {
+			t.Error(err)
+		} else if string(b) != string(expect) {
+			t.Errorf("invalid output:\n%s\n%s", expect, b)
+		}
+
+		if n, err := Length(test); err != nil {
+			t.Error(err)
+		} else if n != len(expect) {
+			t.Errorf("invalid length: %d != %d (%s)", n, len(expect), expect)
+		}
+	}
+}
+
+func TestOmitemptyEmptier(t *testing.T) {
+	v := struct {
+		A emptier    `json:"a,omitempty"`
+		B emptier    `json:"b,omitempty"`
+		C ptrEmptier `json:"c,omitempty"`
+	}{A: emptier{empty: true}}
+
+	const expect = `{"b":{},"c":{}}`
+
+	if b, err := Marshal(v); err != nil {
+		t.Error(err)
+	} else if string(b) != expect {
+		t.Error("invalid output:", string(b))
+	}
+
+	if n, err := Length(v); err != nil {
+		t.Error(err)
+	} else if n != len(expect) {
+		t.Error("invalid length:", n)
+	}
+
+	if n, err := Length(&v); err != nil {
+		t.Error(err)
+	} else if n != len(`{"b":{}}`) {
+		t.Error("invalid length of addressable value:", n)
+	}
+}
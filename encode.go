@@ -0,0 +1,777 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Appender can be implemented by a value to override the default encoding
+// algorithm implemented by the Encoder type.
+type Appender interface {
+	// AppendJSON appends the JSON representation of the value to b and
+	// returns the extended buffer.
+	AppendJSON(b []byte) []byte
+}
+
+// OptionsAppender can be implemented by a value to override the default
+// encoding algorithm implemented by the Encoder type, taking the configuration
+// of the encoder into account. It takes precedence over Appender.
+type OptionsAppender interface {
+	// AppendJSONWithOptions appends the JSON representation of the value to
+	// b, as written by the encoder e, and returns the extended buffer or an
+	// error if the value can't be serialized.
+	AppendJSONWithOptions(b []byte, e *Encoder) ([]byte, error)
+}
+
+var (
+	appenderType        = reflect.TypeOf((*Appender)(nil)).Elem()
+	optionsAppenderType = reflect.TypeOf((*OptionsAppender)(nil)).Elem()
+)
+
+// Encoder carries the configuration used to serialize Go values to JSON.
+//
+// The zero-value is a valid encoder which writes maps in the iteration order
+// of the Go runtime and doesn't escape HTML characters.
+type Encoder struct {
+	// When SortMapKeys is true, the keys of maps are written in the order
+	// used by the standard json package, which sorts them by their string
+	// representation. Each map is sorted once, using pooled buffers to hold
+	// the keys. OrderedMap values always have their keys written in
+	// insertion order.
+	SortMapKeys bool
+
+	// When EscapeHTML is true, the characters <, > and & are escaped in
+	// strings like the standard json package does by default.
+	EscapeHTML bool
+
+	// When ValidateNumbers is true, an error is returned for json.Number
+	// values which aren't valid JSON numbers, like the standard json package
+	// does, instead of writing them as-is.
+	ValidateNumbers bool
+
+	// When Canonical is true, values are written in the canonical form of
+	// RFC 8785 (see Canonicalize): the members of objects, including structs
+	// and ordered maps, are sorted by the UTF-16 code units of their keys,
+	// numbers are formatted like ECMAScript does and strings have minimal
+	// escaping. The other options have no effect on a canonical encoder.
+	Canonical bool
+}
+
+// StdEncoder is the configuration used by Marshal and Append, it escapes and
+// sorts like json.Marshal does (see Marshal for the differences).
+var StdEncoder = Encoder{
+	SortMapKeys:     true,
+	EscapeHTML:      true,
+	ValidateNumbers: true,
+}
+
+// Marshal returns the JSON representation of v, it produces the same output as
+// json.Marshal (including the order of map keys) for the types supported by
+// the jutil package, with these exceptions:
+//
+//   - embedded structs aren't flattened, their fields are written as a nested
+//     object under the name of the embedded field (the name of its type unless
+//     a json tag sets one), where json.Marshal promotes them to the parent
+//     object,
+//   - embedded fields of unexported types aren't supported and return an error,
+//     where json.Marshal promotes their exported fields.
+func Marshal(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append appends the JSON representation of v to dst and returns the extended
+// buffer, it is the equivalent of Marshal for programs which manage their own
+// memory buffers.
+func Append(dst []byte, v interface{}) ([]byte, error) {
+	return StdEncoder.Append(dst, v)
+}
+
+// AppendString appends s to dst as a JSON string, escaped the way Append
+// escapes strings.
+func AppendString(dst []byte, s string) []byte {
+	return appendString(dst, s, true)
+}
+
+// AppendFloat appends f to dst, formatted the way the standard json package
+// formats floating point numbers of the given bit size (32 or 64). An error is
+// returned if f is NaN or an infinity.
+func AppendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	return appendFloat(dst, f, bits)
+}
+
+// Marshal returns the JSON representation of v.
+func (e *Encoder) Marshal(v interface{}) ([]byte, error) {
+	return e.Append(nil, v)
+}
+
+// AppendString appends s to dst as a JSON string, escaped with the options of
+// the encoder.
+func (e *Encoder) AppendString(dst []byte, s string) []byte {
+	return appendString(dst, s, e.EscapeHTML)
+}
+
+// Append appends the JSON representation of v to dst and returns the extended
+// buffer. If an error occurs the returned buffer may contain partial output.
+func (e *Encoder) Append(dst []byte, v interface{}) ([]byte, error) {
+	var b []byte
+	var err error
+
+	if v == nil {
+		return append(dst, "null"...), nil
+	}
+
+	if e.Canonical {
+		return e.appendCanonical(dst, v)
+	}
+
+	switch x := v.(type) {
+	case bool:
+		dst = strconv.AppendBool(dst, x)
+
+	case int:
+		dst = strconv.AppendInt(dst, int64(x), 10)
+
+	case int8:
+		dst = strconv.AppendInt(dst, int64(x), 10)
+
+	case int16:
+		dst = strconv.AppendInt(dst, int64(x), 10)
+
+	case int32:
+		dst = strconv.AppendInt(dst, int64(x), 10)
+
+	case int64:
+		dst = strconv.AppendInt(dst, x, 10)
+
+	case uint:
+		dst = strconv.AppendUint(dst, uint64(x), 10)
+
+	case uint8:
+		dst = strconv.AppendUint(dst, uint64(x), 10)
+
+	case uint16:
+		dst = strconv.AppendUint(dst, uint64(x), 10)
+
+	case uint32:
+		dst = strconv.AppendUint(dst, uint64(x), 10)
+
+	case uint64:
+		dst = strconv.AppendUint(dst, x, 10)
+
+	case float32:
+		dst, err = appendFloat(dst, float64(x), 32)
+
+	case float64:
+		dst, err = appendFloat(dst, x, 64)
+
+	case string:
+		dst = appendString(dst, x, e.EscapeHTML)
+
+	case []byte:
+		dst = appendBytes(dst, x)
+
+	case map[string]interface{}:
+		dst, err = e.appendMapStringInterface(dst, x)
+
+	case []interface{}:
+		dst, err = e.appendSliceInterface(dst, x)
+
+	case *OrderedMap:
+		dst, err = e.appendOrderedMap(dst, x)
+
+	case Value:
+		if len(x) == 0 {
+			dst = append(dst, "null"...)
+		} else {
+			dst, err = e.appendCompact(dst, x)
+		}
+
+	case json.Number:
+		if len(x) == 0 {
+			dst = append(dst, '0')
+		} else if e.ValidateNumbers && !isValidNumber(string(x)) {
+			err = fmt.Errorf("json: invalid number literal %q", string(x))
+		} else {
+			dst = append(dst, x...)
+		}
+
+	case *big.Int:
+		dst = appendBigInt(dst, x)
+
+	case *big.Float:
+		dst = appendBigFloat(dst, x)
+
+	case *big.Rat:
+		dst = appendBigRat(dst, x)
+
+	case OptionsAppender:
+		if isNilPointer(x) {
+			dst = append(dst, "null"...)
+		} else {
+			dst, err = x.AppendJSONWithOptions(dst, e)
+		}
+
+	case Appender:
+		if isNilPointer(x) {
+			dst = append(dst, "null"...)
+		} else {
+			dst = x.AppendJSON(dst)
+		}
+
+	case json.Marshaler:
+		if isNilPointer(x) {
+			dst = append(dst, "null"...)
+		} else if b, err = x.MarshalJSON(); err == nil {
+			dst, err = e.appendCompact(dst, b)
+		}
+		if err != nil {
+			err = &json.MarshalerError{Type: reflect.TypeOf(x), Err: err}
+		}
+
+	case encoding.TextMarshaler:
+		if isNilPointer(x) {
+			dst = append(dst, "null"...)
+		} else if b, err = x.MarshalText(); err == nil {
+			dst = appendString(dst, string(b), e.EscapeHTML)
+		} else {
+			err = &json.MarshalerError{Type: reflect.TypeOf(x), Err: err}
+		}
+
+	default:
+		dst, err = e.appendV(dst, reflect.ValueOf(v))
+	}
+
+	return dst, err
+}
+
+func (e *Encoder) appendV(dst []byte, v reflect.Value) ([]byte, error) {
+	switch t := v.Type(); t.Kind() {
+	case reflect.Struct:
+		return e.appendStruct(dst, t, v)
+
+	case reflect.Map:
+		return e.appendMap(dst, v)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !isMarshalerType(reflect.PtrTo(t.Elem())) {
+			return appendBytes(dst, v.Bytes()), nil // []byte
+		}
+		return e.appendArray(dst, v)
+
+	case reflect.Array:
+		return e.appendArray(dst, v)
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		return e.appendElem(dst, v.Elem())
+
+	case reflect.Bool:
+		return strconv.AppendBool(dst, v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(dst, v.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(dst, v.Uint(), 10), nil
+
+	case reflect.Float32:
+		return appendFloat(dst, v.Float(), 32)
+
+	case reflect.Float64:
+		return appendFloat(dst, v.Float(), 64)
+
+	case reflect.String:
+		return appendString(dst, v.String(), e.EscapeHTML), nil
+
+	default:
+		return dst, &json.UnsupportedTypeError{Type: t}
+	}
+}
+
+// appendElem encodes a value nested in v, going through Append so the methods
+// of the value are taken into account. If it is addressable the methods of its
+// pointer type are used like the standard json package does.
+func (e *Encoder) appendElem(dst []byte, v reflect.Value) ([]byte, error) {
+	if !v.CanInterface() {
+		return dst, fmt.Errorf("reflect: cannot call Interface on %v", v)
+	}
+
+	if v.CanAddr() {
+		p := v.Addr()
+
+		if isEncoderMethodsType(p.Type()) {
+			return e.Append(dst, p.Interface())
+		}
+
+		// Structs and arrays are walked with the addressable value so the
+		// pointer methods of their fields and elements are also used.
+		switch v.Kind() {
+		case reflect.Struct, reflect.Array:
+			if !isEncoderMethodsType(v.Type()) {
+				return e.appendV(dst, v)
+			}
+		}
+	}
+
+	return e.Append(dst, v.Interface())
+}
+
+// isEncoderMethodsType returns true if t implements one of the interfaces that
+// Append uses to encode values.
+func isEncoderMethodsType(t reflect.Type) bool {
+	return t.Implements(optionsAppenderType) || t.Implements(appenderType) || isMarshalerType(t)
+}
+
+func (e *Encoder) appendStruct(dst []byte, t reflect.Type, v reflect.Value) ([]byte, error) {
+	var err error
+	var s = LookupStruct(t)
+
+	dst = append(dst, '{')
+	n := len(dst)
+
+	for _, f := range s {
+		fv := v.FieldByIndex(f.Index)
+
+		if f.Omit(fv) {
+			continue
+		}
+
+		if len(dst) != n {
+			dst = append(dst, ',')
+		}
+
+		dst = appendString(dst, f.Name, e.EscapeHTML)
+		dst = append(dst, ':')
+
+		if dst, err = e.appendElem(dst, fv); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+func (e *Encoder) appendArray(dst []byte, v reflect.Value) ([]byte, error) {
+	var err error
+
+	dst = append(dst, '[')
+
+	for i, j := 0, v.Len(); i != j; i++ {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		if dst, err = e.appendElem(dst, v.Index(i)); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, ']'), nil
+}
+
+func (e *Encoder) appendMap(dst []byte, v reflect.Value) ([]byte, error) {
+	var err error
+
+	if v.IsNil() {
+		return append(dst, "null"...), nil
+	}
+
+	if !isMapKeyType(v.Type().Key()) {
+		return dst, &json.UnsupportedTypeError{Type: v.Type()}
+	}
+
+	dst = append(dst, '{')
+
+	if !e.SortMapKeys {
+		for i, it := 0, v.MapRange(); it.Next(); i++ {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = e.appendMapKey(dst, it.Key()); err != nil {
+				return dst, err
+			}
+			if dst, err = e.appendElem(dst, it.Value()); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, '}'), nil
+	}
+
+	buf := mapEntryPool.Get().(*mapEntries)
+	entries := (*buf)[:0]
+
+	defer func() { releaseMapEntries(buf, entries) }()
+
+	for it := v.MapRange(); it.Next(); {
+		k, err := resolveMapKey(it.Key())
+		if err != nil {
+			return dst, err
+		}
+		entries = append(entries, mapEntry{key: k, value: it.Value()})
+	}
+
+	sort.Sort((*mapEntries)(&entries))
+
+	for i, entry := range entries {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, entry.key, e.EscapeHTML)
+		dst = append(dst, ':')
+		if dst, err = e.appendElem(dst, entry.value); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+func (e *Encoder) appendMapKey(dst []byte, k reflect.Value) ([]byte, error) {
+	switch k.Kind() {
+	case reflect.String:
+		dst = appendString(dst, k.String(), e.EscapeHTML)
+	default:
+		s, err := resolveMapKey(k)
+		if err != nil {
+			return dst, err
+		}
+		dst = appendString(dst, s, e.EscapeHTML)
+	}
+	return append(dst, ':'), nil
+}
+
+func (e *Encoder) appendSliceInterface(dst []byte, s []interface{}) ([]byte, error) {
+	var err error
+
+	if s == nil {
+		return append(dst, "null"...), nil
+	}
+
+	dst = append(dst, '[')
+
+	for i, v := range s {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		if dst, err = e.Append(dst, v); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, ']'), nil
+}
+
+func (e *Encoder) appendMapStringInterface(dst []byte, m map[string]interface{}) ([]byte, error) {
+	var err error
+
+	if m == nil {
+		return append(dst, "null"...), nil
+	}
+
+	dst = append(dst, '{')
+
+	if !e.SortMapKeys {
+		i := 0
+		for k, v := range m {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendString(dst, k, e.EscapeHTML)
+			dst = append(dst, ':')
+			if dst, err = e.Append(dst, v); err != nil {
+				return dst, err
+			}
+			i++
+		}
+		return append(dst, '}'), nil
+	}
+
+	buf := stringsPool.Get().(*[]string)
+	keys := (*buf)[:0]
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, k, e.EscapeHTML)
+		dst = append(dst, ':')
+		if dst, err = e.Append(dst, m[k]); err != nil {
+			break
+		}
+	}
+
+	for i := range keys {
+		keys[i] = "" // don't retain references to the map keys
+	}
+	*buf = keys[:0]
+	stringsPool.Put(buf)
+
+	if err != nil {
+		return dst, err
+	}
+
+	return append(dst, '}'), nil
+}
+
+// appendCompact appends the output of a json.Marshaler to dst, the content is
+// validated and compacted the same way the standard json package does.
+func (e *Encoder) appendCompact(dst []byte, b []byte) ([]byte, error) {
+	n := len(dst)
+
+	dst, err := Compact(dst, b)
+	if err != nil || !e.EscapeHTML {
+		return dst, err
+	}
+
+	for i := n; i < len(dst); i++ {
+		if c := dst[i]; c == '<' || c == '>' || c == '&' || (c == 0xE2 && i+2 < len(dst) && dst[i+1] == 0x80 && dst[i+2]&^1 == 0xA8) {
+			return appendEscapedHTML(dst[:i], dst[i:]), nil
+		}
+	}
+
+	return dst, nil
+}
+
+// appendEscapedHTML appends b to dst with the HTML characters and the U+2028
+// and U+2029 line separators escaped, b must not overlap with the free
+// capacity of dst.
+func appendEscapedHTML(dst []byte, b []byte) []byte {
+	b = append([]byte(nil), b...)
+
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '<' || c == '>' || c == '&':
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+		case c == 0xE2 && i+2 < len(b) && b[i+1] == 0x80 && b[i+2]&^1 == 0xA8:
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[b[i+2]&0xF])
+			i += 2
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+// appendString appends s to dst as a JSON string, escaped the same way the
+// standard json package does.
+func appendString(dst []byte, s string, escapeHTML bool) []byte {
+	dst = append(dst, '"')
+	dst = appendEscapedString(dst, s, escapeHTML)
+	return append(dst, '"')
+}
+
+// appendEscapedString appends the escaped content of s to dst, without the
+// surrounding quotes.
+func appendEscapedString(dst []byte, s string, escapeHTML bool) []byte {
+	i := 0
+
+	for j := 0; j < len(s); {
+		c := s[j]
+
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || (c != '<' && c != '>' && c != '&')) {
+				j++
+				continue
+			}
+
+			dst = append(dst, s[i:j]...)
+
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+
+			j++
+			i = j
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[j:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, s[i:j]...)
+			dst = append(dst, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, s[i:j]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+		default:
+			j += size
+			continue
+		}
+
+		j += size
+		i = j
+	}
+
+	return append(dst, s[i:]...)
+}
+
+// writeString writes s to w as a JSON string, escaped the same way the standard
+// json package does without HTML escaping.
+func writeString(w *bytes.Buffer, s string) {
+	w.Write(appendString(nil, s, false))
+}
+
+func appendBytes(dst []byte, b []byte) []byte {
+	if b == nil {
+		return append(dst, "null"...)
+	}
+
+	n := base64.StdEncoding.EncodedLen(len(b))
+	i := len(dst) + 1
+
+	if cap(dst)-len(dst) < n+2 {
+		dst = append(make([]byte, 0, len(dst)+n+2), dst...)
+	}
+
+	dst = dst[:i+n+1]
+	dst[i-1] = '"'
+	base64.StdEncoding.Encode(dst[i:], b)
+	dst[i+n] = '"'
+	return dst
+}
+
+// appendFloat appends f to dst, formatted the way the standard json package
+// formats floating point numbers of the given bit size.
+func appendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return dst, &json.UnsupportedValueError{
+			Value: reflect.ValueOf(f),
+			Str:   strconv.FormatFloat(f, 'g', -1, bits),
+		}
+	}
+
+	// Use the shortest representation, switching to the exponent format for
+	// very small or very large numbers like ECMAScript does.
+	format := byte('f')
+
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+// resolveMapKey returns the string representation of a map key, following the
+// rules of the standard json package.
+func resolveMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if k.CanInterface() {
+		if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+			if k.Kind() == reflect.Ptr && k.IsNil() {
+				return "", nil
+			}
+			b, err := m.MarshalText()
+			if err != nil {
+				return "", &json.MarshalerError{Type: k.Type(), Err: err}
+			}
+			return string(b), nil
+		}
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+func isMapKeyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshalerType)
+}
+
+func isNilPointer(v interface{}) bool {
+	r := reflect.ValueOf(v)
+	return r.Kind() == reflect.Ptr && r.IsNil()
+}
+
+// releaseMapEntries puts buf back in the pool, entries is the slice that was
+// obtained from it.
+func releaseMapEntries(buf *mapEntries, entries []mapEntry) {
+	for i := range entries {
+		entries[i] = mapEntry{} // don't retain references to the map
+	}
+	*buf = entries[:0]
+	mapEntryPool.Put(buf)
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+type mapEntries []mapEntry
+
+func (m *mapEntries) Len() int           { return len(*m) }
+func (m *mapEntries) Less(i, j int) bool { return (*m)[i].key < (*m)[j].key }
+func (m *mapEntries) Swap(i, j int)      { (*m)[i], (*m)[j] = (*m)[j], (*m)[i] }
+
+const (
+	hex = "0123456789abcdef"
+)
+
+var (
+	// The encoder uses these pools to hold the keys of the maps it sorts, so
+	// serializing a map costs a single sort and no memory allocation once the
+	// program reached its steady state.
+	mapEntryPool = sync.Pool{New: func() interface{} { return new(mapEntries) }}
+	stringsPool  = sync.Pool{New: func() interface{} { return new([]string) }}
+)
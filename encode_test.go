@@ -0,0 +1,376 @@
+package jutil
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encodeMarshaler struct{ s string }
+
+func (m encodeMarshaler) MarshalJSON() ([]byte, error) {
+	if len(m.s) == 0 {
+		return nil, errors.New("empty")
+	}
+	return []byte(m.s), nil
+}
+
+type encodeAppender struct{}
+
+func (encodeAppender) AppendJSON(b []byte) []byte {
+	return append(b, `"appender"`...)
+}
+
+type encodeOptionsAppender struct{ S string }
+
+func (v encodeOptionsAppender) AppendJSONWithOptions(b []byte, e *Encoder) ([]byte, error) {
+	if v.S == "error" {
+		return b, errors.New("error")
+	}
+	return e.AppendString(b, v.S), nil
+}
+
+func (encodeOptionsAppender) AppendJSON(b []byte) []byte {
+	return append(b, `"appender"`...)
+}
+
+// EncodeEmbedded is exported so its fields are promoted by json.Marshal when
+// it is embedded.
+type EncodeEmbedded struct{ S string }
+
+type encodeKey int
+
+func (k encodeKey) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("k", int(k))), nil
+}
+
+type encodePtrMarshaler struct{ X int }
+
+func (*encodePtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"pointer"`), nil
+}
+
+type encodeByte byte
+
+func (b encodeByte) MarshalText() ([]byte, error) {
+	return []byte{'a' + byte(b)}, nil
+}
+
+var encodeTests = []interface{}{
+	nil,
+	true,
+	false,
+	0,
+	-42,
+	int8(math.MinInt8),
+	int64(math.MinInt64),
+	uint64(math.MaxUint64),
+	uintptr(1),
+	0.0,
+	-0.5,
+	1e20,
+	1e21,
+	1e-6,
+	1e-7,
+	123456789.123,
+	float32(0.1),
+	float32(1e21),
+	float32(1e-7),
+	math.MaxFloat64,
+	math.SmallestNonzeroFloat64,
+	"",
+	"Hello World!",
+	"<a href=\"/\">&amp;</a>",
+	"\x00\x01\b\f\n\r\t\x1f\x7f",
+	"\u2028\u2029 é 😀",
+	"\xff\xfe invalid \xc3",
+	[]byte(nil),
+	[]byte{},
+	[]byte("Hello World!"),
+	[]int(nil),
+	[]int{},
+	[]int{1, 2, 3},
+	[3]string{"a", "b", "c"},
+	[]interface{}{nil, 1, "2", []interface{}{3.5}},
+	map[string]interface{}(nil),
+	map[string]interface{}{},
+	map[string]interface{}{"z": 1, "a": 2, "m": map[string]interface{}{"c": nil, "b": true}, "<": ">"},
+	map[string]int{"b": 1, "a": 2, "c": 3, "": 4},
+	map[int]string{10: "A", 2: "B", -1: "C", 100: "D"},
+	map[uint8]bool{10: true, 9: false},
+	map[encodeKey]int{3: 3, 1: 1, 2: 2},
+	map[string][]string{"x": {"y"}, "w": nil},
+	json.Number("1.5e10"),
+	json.RawMessage("{ \"a\" : [ 1 , 2 ] , \"<\" : \"\u2028\" }"),
+	encodeMarshaler{`"marshaler"`},
+	&encodeMarshaler{`[ 1 ]`},
+	(*encodeMarshaler)(nil),
+	time.Date(2016, 12, 25, 10, 30, 15, 123456789, time.UTC),
+	net.IPv4(127, 0, 0, 1),
+	struct{}{},
+	struct {
+		A int
+		B string `json:"b,omitempty"`
+		C []string
+		D *int
+		E interface{}
+		F map[int]int `json:"f"`
+		G float32
+		H bool `json:"-"`
+		i int
+	}{A: 1, C: []string{"€"}, E: 2, F: map[int]int{2: 1, 1: 2}, G: 0.3},
+	&struct{ Value *int }{},
+}
+
+func TestMarshal(t *testing.T) {
+	for _, test := range encodeTests {
+		expect, err := json.Marshal(test)
+		if err != nil {
+			t.Errorf("%#v: json.Marshal: %s", test, err)
+			continue
+		}
+
+		if b, err := Marshal(test); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if string(b) != string(expect) {
+			t.Errorf("%#v: invalid output:\n%s\n%s", test, string(expect), string(b))
+		}
+	}
+}
+
+func TestMarshalPointerMethods(t *testing.T) {
+	type T struct {
+		A encodePtrMarshaler
+		B [2]encodePtrMarshaler
+		C []encodePtrMarshaler
+		D map[string]encodePtrMarshaler
+		E []encodeByte
+		F struct{ G encodePtrMarshaler }
+	}
+
+	v := T{
+		C: []encodePtrMarshaler{{}},
+		D: map[string]encodePtrMarshaler{"a": {}},
+		E: []encodeByte{0, 1, 2},
+	}
+
+	for _, test := range []interface{}{v, &v, []T{v}, []encodeByte{3}} {
+		expect, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b, err := Marshal(test); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if string(b) != string(expect) {
+			t.Errorf("%#v: invalid output:\n%s\n%s", test, string(expect), string(b))
+		}
+
+		if n, err := LengthWithOptions(test, StdLengthOptions); err != nil {
+			t.Errorf("%#v: %s", test, err)
+		} else if n != len(expect) {
+			t.Errorf("%#v: invalid length: %d != %d", test, len(expect), n)
+		}
+	}
+}
+
+func TestMarshalEmbedded(t *testing.T) {
+	type T struct {
+		EncodeEmbedded
+		Inner struct{ A int }
+		B     int
+	}
+	type U struct {
+		T `json:"t"`
+		C int
+	}
+	type unexported struct{ D int }
+	type V struct {
+		unexported
+	}
+
+	tests := []struct {
+		value  interface{}
+		expect string
+	}{
+		{T{EncodeEmbedded: EncodeEmbedded{"a"}, B: 1}, `{"EncodeEmbedded":{"S":"a"},"Inner":{"A":0},"B":1}`},
+		{U{T: T{EncodeEmbedded: EncodeEmbedded{"a"}, B: 1}, C: 2}, `{"t":{"EncodeEmbedded":{"S":"a"},"Inner":{"A":0},"B":1},"C":2}`},
+	}
+
+	for _, test := range tests {
+		// json.Marshal promotes the fields of embedded structs instead.
+		if b, err := json.Marshal(test.value); err != nil {
+			t.Error(err)
+		} else if string(b) == test.expect {
+			t.Errorf("%#v: unexpected output of json.Marshal: %s", test.value, string(b))
+		}
+
+		if b, err := Marshal(test.value); err != nil {
+			t.Errorf("%#v: %s", test.value, err)
+		} else if string(b) != test.expect {
+			t.Errorf("%#v: invalid output:\n%s\n%s", test.value, test.expect, string(b))
+		}
+
+		if n, err := LengthWithOptions(test.value, StdLengthOptions); err != nil {
+			t.Errorf("%#v: %s", test.value, err)
+		} else if n != len(test.expect) {
+			t.Errorf("%#v: invalid length: %d != %d", test.value, len(test.expect), n)
+		}
+	}
+
+	if _, err := Marshal(V{}); err == nil {
+		t.Error("expected an error for an embedded field of an unexported type")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	b, err := Append([]byte("prefix:"), map[string]int{"b": 2, "a": 1})
+	if err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `prefix:{"a":1,"b":2}` {
+		t.Error("invalid output:", s)
+	}
+}
+
+func TestEncoderUnsorted(t *testing.T) {
+	e := Encoder{}
+
+	for _, test := range encodeTests {
+		expect, _ := json.Marshal(test)
+
+		b, err := e.Marshal(test)
+		if err != nil {
+			t.Errorf("%#v: %s", test, err)
+			continue
+		}
+
+		var v1, v2 interface{}
+		json.Unmarshal(expect, &v1)
+		json.Unmarshal(b, &v2)
+
+		if !reflect.DeepEqual(v1, v2) {
+			t.Errorf("%#v: invalid output:\n%s\n%s", test, string(expect), string(b))
+		}
+	}
+}
+
+func TestEncoderEscapeHTML(t *testing.T) {
+	e := Encoder{SortMapKeys: true}
+
+	b, err := e.Marshal(map[string]interface{}{"<a>": "&", "b": json.RawMessage("\"<\u2028>\"")})
+	if err != nil {
+		t.Error(err)
+	} else if s := string(b); s != "{\"<a>\":\"&\",\"b\":\"<\u2028>\"}" {
+		t.Error("invalid output:", s)
+	}
+}
+
+func TestMarshalAppender(t *testing.T) {
+	b, err := Marshal([]interface{}{encodeAppender{}, (*encodeAppender)(nil)})
+	if err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `["appender",null]` {
+		t.Error("invalid output:", s)
+	}
+}
+
+func TestMarshalOptionsAppender(t *testing.T) {
+	v := []interface{}{encodeOptionsAppender{S: "<>"}, (*encodeOptionsAppender)(nil)}
+
+	for _, test := range []struct {
+		e      Encoder
+		expect string
+	}{
+		{Encoder{}, `["<>",null]`},
+		{StdEncoder, `["\u003c\u003e",null]`},
+	} {
+		if b, err := test.e.Marshal(v); err != nil {
+			t.Error(err)
+		} else if s := string(b); s != test.expect {
+			t.Error("invalid output:", s)
+		}
+	}
+
+	if _, err := Marshal(map[string]interface{}{"a": encodeOptionsAppender{S: "error"}}); err == nil {
+		t.Error("expected an error from the AppendJSONWithOptions method")
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	tests := []interface{}{
+		math.NaN(),
+		math.Inf(1),
+		float32(math.Inf(-1)),
+		[]interface{}{1, math.NaN()},
+		make(chan int),
+		func() {},
+		map[float64]int{1: 1},
+		map[string]interface{}{"a": complex(1, 2)},
+		encodeMarshaler{},
+		encodeMarshaler{`{"a"}`},
+		json.Number("1.5.0"),
+		[]interface{}{json.Number("NaN")},
+	}
+
+	for _, test := range tests {
+		if _, err := Marshal(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+func TestEncoderNumbers(t *testing.T) {
+	e := Encoder{}
+
+	b, err := e.Marshal([]json.Number{"", "1.5", "abc"})
+	if err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `[0,1.5,abc]` {
+		t.Error("invalid output:", s)
+	}
+
+	e.ValidateNumbers = true
+
+	if _, err := e.Marshal(json.Number("abc")); err == nil {
+		t.Error("expected an error for an invalid number")
+	}
+}
+
+func TestMarshalMapAllocs(t *testing.T) {
+	m := map[string]interface{}{"a": 1, "b": "2", "c": nil, "d": true}
+	b := make([]byte, 0, 1024)
+
+	Append(b, m) // warm up the pool
+
+	if n := testing.AllocsPerRun(100, func() { Append(b[:0], m) }); n != 0 {
+		t.Error("too many memory allocations:", n)
+	}
+}
+
+func BenchmarkMarshalMapSorted(b *testing.B) {
+	m := map[string]interface{}{}
+	for _, k := range strings.Fields("the quick brown fox jumps over the lazy dog") {
+		m[k] = map[string]int{k: len(k), "": 0}
+	}
+	buf := make([]byte, 0, 1024)
+
+	for i := 0; i != b.N; i++ {
+		buf, _ = Append(buf[:0], m)
+	}
+}
+
+func BenchmarkMarshalMapSortedStd(b *testing.B) {
+	m := map[string]interface{}{}
+	for _, k := range strings.Fields("the quick brown fox jumps over the lazy dog") {
+		m[k] = map[string]int{k: len(k), "": 0}
+	}
+
+	for i := 0; i != b.N; i++ {
+		json.Marshal(m)
+	}
+}
@@ -2,7 +2,10 @@ package jutil
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // EscapeString takes a string as argument and returns the version of that
@@ -25,99 +28,157 @@ func Escape(b []byte) []byte {
 // WriteEscaped outputs a byte slice into an io.Writer where every special
 // character has been escaped according to the JSON formatting rules.
 func WriteEscaped(w io.Writer, b []byte) (n int, err error) {
-	var e [1]byte
+	var e [2]byte
 	var k int
 
-	if len(b) < 100 {
-		// Fast path optimization for short strings that don't have any
-		// characters to escape.
-		const escapedBytes = "/\"\\\n\t\r\v\b\f"
-
-		switch len(b) {
-		case 0:
-		case 1:
-			for i := range escapedBytes {
-				if b[0] == escapedBytes[i] {
-					goto loop
-				}
-			}
-		default:
-			for i := range escapedBytes {
-				if bytes.IndexByte(b, escapedBytes[i]) >= 0 {
-					goto loop
-				}
-			}
+	e[0] = '\\'
+
+	for len(b) != 0 {
+		i := escapeIndex(b)
+		if i < 0 {
+			break
 		}
 
-		return w.Write(b)
-	}
+		k, err = w.Write(b[:i])
+		n += k
+		if err != nil {
+			return
+		}
 
-loop:
-	for len(b) != 0 {
-		for i, c := range b {
+		e[1] = escapeChar[b[i]]
+		k, err = w.Write(e[:])
+		n += k
+		if err != nil {
+			return
+		}
 
-			switch c {
-			case '"':
-				e[0] = '"'
+		b = b[i+1:]
+	}
 
-			case '/':
-				e[0] = '/'
+	if len(b) != 0 {
+		k, err = w.Write(b)
+		n += k
+	}
 
-			case '\\':
-				e[0] = '\\'
+	return
+}
 
-			case '\n':
-				e[0] = 'n'
+// UnescapeString takes a string as argument and returns the version of that
+// string where every escape sequence has been decoded according to the JSON
+// formatting rules.
+func UnescapeString(s string) (string, error) {
+	b, err := Unescape([]byte(s))
+	return string(b), err
+}
 
-			case '\t':
-				e[0] = 't'
+// Unescape takes a byte slice as argument and returns the copy of that slice
+// where every escape sequence has been decoded according to the JSON
+// formatting rules.
+//
+// The function is the inverse of Escape, it also accepts the `\v` sequence
+// produced by Escape even though it is not part of the JSON specification.
+func Unescape(b []byte) ([]byte, error) {
+	i := bytes.IndexByte(b, '\\')
+	if i < 0 {
+		return append(make([]byte, 0, len(b)), b...), nil
+	}
+	return appendUnescaped(append(make([]byte, 0, len(b)), b[:i]...), b[i:])
+}
 
-			case '\r':
-				e[0] = 'r'
+func appendUnescaped(dst []byte, b []byte) ([]byte, error) {
+	for len(b) != 0 {
+		i := bytes.IndexByte(b, '\\')
+		if i < 0 {
+			dst = append(dst, b...)
+			break
+		}
 
-			case '\v':
-				e[0] = 'v'
+		dst = append(dst, b[:i]...)
+		b = b[i:]
 
-			case '\b':
-				e[0] = 'b'
+		if len(b) < 2 {
+			return dst, errInvalidEscape
+		}
 
-			case '\f':
-				e[0] = 'f'
+		switch c := b[1]; c {
+		case '"', '\\', '/':
+			dst = append(dst, c)
+		case 'n':
+			dst = append(dst, '\n')
+		case 't':
+			dst = append(dst, '\t')
+		case 'r':
+			dst = append(dst, '\r')
+		case 'v':
+			dst = append(dst, '\v')
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'u':
+			r, n := unescapeRune(b)
+			if n == 0 {
+				return dst, errInvalidEscape
 			}
+			dst = appendRune(dst, r)
+			b = b[n:]
+			continue
+		default:
+			return dst, errInvalidEscape
+		}
+
+		b = b[2:]
+	}
+
+	return dst, nil
+}
+
+// unescapeRune decodes the \uXXXX sequence at the beginning of b, combining
+// surrogate pairs, and returns the rune and the number of bytes consumed.
+func unescapeRune(b []byte) (r rune, n int) {
+	if r = unescapeHex(b); r < 0 {
+		return
+	}
 
-			if e[0] != 0 {
-				k, err = w.Write(b[:i])
-				n += k
-				if err != nil {
-					return
-				}
-
-				k, err = w.Write(escape[:])
-				n += k
-				if err != nil {
-					return
-				}
-
-				k, err = w.Write(e[:])
-				n += k
-				if err != nil {
-					return
-				}
-
-				e[0] = 0
-				b = b[i+1:]
-				continue loop
+	if utf16.IsSurrogate(r) {
+		if r2 := unescapeHex(b[6:]); r2 >= 0 {
+			if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+				return r, 12
 			}
 		}
+		return utf8.RuneError, 6
+	}
 
-		k, err = w.Write(b)
-		n += k
-		break
+	return r, 6
+}
+
+func unescapeHex(b []byte) (r rune) {
+	if len(b) < 6 || b[0] != '\\' || b[1] != 'u' {
+		return -1
+	}
+
+	for _, c := range b[2:6] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return -1
+		}
+		r = r<<4 | rune(c)
 	}
 
 	return
 }
 
+func appendRune(b []byte, r rune) []byte {
+	var e [utf8.UTFMax]byte
+	return append(b, e[:utf8.EncodeRune(e[:], r)]...)
+}
+
 var (
-	escape = [...]byte{'\\'}
+	errInvalidEscape = errors.New("jutil: invalid escape sequence")
 )
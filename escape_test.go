@@ -1,6 +1,10 @@
 package jutil
 
-import "testing"
+import (
+	"io"
+	"strings"
+	"testing"
+)
 
 func TestEscapeString(t *testing.T) {
 	tests := []struct {
@@ -59,3 +63,111 @@ func TestEscapeString(t *testing.T) {
 		}
 	}
 }
+
+func TestUnescapeString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  ``,
+			out: "",
+		},
+		{
+			in:  `Hello World!`,
+			out: "Hello World!",
+		},
+		{
+			in:  `Hello\"World!`,
+			out: "Hello\"World!",
+		},
+		{
+			in:  `Hello\/World!`,
+			out: "Hello/World!",
+		},
+		{
+			in:  `Hello\\World!`,
+			out: "Hello\\World!",
+		},
+		{
+			in:  `Hello\n\t\r\v\b\fWorld!`,
+			out: "Hello\n\t\r\v\b\fWorld!",
+		},
+		{
+			in:  `étÉ`,
+			out: "étÉ",
+		},
+		{
+			in:  `😀!`,
+			out: "😀!",
+		},
+		{
+			in:  `\ud83d!`,
+			out: "�!",
+		},
+	}
+
+	for _, test := range tests {
+		if s, err := UnescapeString(test.in); err != nil {
+			t.Errorf("%#v: %s", test.in, err)
+		} else if s != test.out {
+			t.Errorf("%#v: invalid unescaped string: %#v != %#v", test.in, test.out, s)
+		}
+	}
+}
+
+func TestUnescapeStringError(t *testing.T) {
+	tests := []string{
+		`\`,
+		`\x`,
+		`\u12`,
+		`\u12G4`,
+	}
+
+	for _, test := range tests {
+		if _, err := UnescapeString(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+// escapeTextBody is a multi-kilobyte text with a few characters to escape.
+var escapeTextBody = strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. ", 30) +
+	"\n\"quoted\" and a/path\n" +
+	strings.Repeat("Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat.\t", 10)
+
+func BenchmarkWriteEscapedShort(b *testing.B) {
+	s := []byte("Hello World!")
+	b.SetBytes(int64(len(s)))
+
+	for i := 0; i != b.N; i++ {
+		WriteEscaped(io.Discard, s)
+	}
+}
+
+func BenchmarkWriteEscapedLong(b *testing.B) {
+	s := []byte(escapeTextBody)
+	b.SetBytes(int64(len(s)))
+
+	for i := 0; i != b.N; i++ {
+		WriteEscaped(io.Discard, s)
+	}
+}
+
+func BenchmarkLengthStringShort(b *testing.B) {
+	s := "Hello World!"
+	b.SetBytes(int64(len(s)))
+
+	for i := 0; i != b.N; i++ {
+		benchSink = jsonLenString(s)
+	}
+}
+
+func BenchmarkLengthStringLong(b *testing.B) {
+	s := escapeTextBody
+	b.SetBytes(int64(len(s)))
+
+	for i := 0; i != b.N; i++ {
+		benchSink = jsonLenString(s)
+	}
+}
@@ -0,0 +1,271 @@
+package jutil
+
+import (
+	"bytes"
+	"io"
+)
+
+// Compact appends to dst the JSON content of src with insignificant whitespaces
+// removed, and returns the extended buffer.
+//
+// src must contain a single valid JSON value, if it doesn't a *SyntaxError is
+// returned and dst is left unchanged.
+func Compact(dst []byte, src []byte) ([]byte, error) {
+	if err := Validate(src); err != nil {
+		return dst, err
+	}
+	f := formatter{}
+	return f.format(dst, src), nil
+}
+
+// Indent appends to dst an indented version of the JSON content of src, and
+// returns the extended buffer.
+//
+// Each element of objects and arrays begins on a new line starting with prefix
+// followed by one or more copies of indent according to the nesting depth. The
+// output doesn't begin with prefix nor end with a newline, to make it easier
+// to embed inside other formatted JSON content, and empty objects and arrays
+// are left on a single line.
+//
+// src must contain a single valid JSON value, if it doesn't a *SyntaxError is
+// returned and dst is left unchanged.
+func Indent(dst []byte, src []byte, prefix string, indent string) ([]byte, error) {
+	if err := Validate(src); err != nil {
+		return dst, err
+	}
+	f := formatter{indent: true, prefix: prefix, step: indent}
+	return f.format(dst, src), nil
+}
+
+// FormatWriter is an io.Writer which reformats the JSON content written to it
+// before passing it to the underlying writer.
+//
+// The content is processed as it flows through, the writer only retains state
+// about the current nesting depth and the string being written, and never
+// buffers whole documents. Streams of documents are supported, each one being
+// written on its own line.
+//
+// The writer does not validate its input, syntax errors are passed through
+// and may produce unexpected output.
+type FormatWriter struct {
+	// When NormalizeEscapes is true, the escape sequences of strings are
+	// decoded and the strings escaped again the way the standard json
+	// package does (without escaping HTML characters). Strings are buffered
+	// until they are complete in this mode.
+	//
+	// The rules of WriteEscaped are deliberately not used here: it writes
+	// \v sequences and leaves some control characters unescaped, which
+	// would turn valid JSON input into invalid output.
+	NormalizeEscapes bool
+
+	w   io.Writer
+	buf []byte
+	formatter
+}
+
+// NewCompactWriter returns a FormatWriter that removes insignificant
+// whitespaces from the JSON content written to it and outputs it to w.
+func NewCompactWriter(w io.Writer) *FormatWriter {
+	return &FormatWriter{w: w, formatter: formatter{stream: true}}
+}
+
+// NewIndentWriter returns a FormatWriter that indents the JSON content written
+// to it and outputs it to w, see Indent for details about the output format.
+func NewIndentWriter(w io.Writer, prefix string, indent string) *FormatWriter {
+	return &FormatWriter{w: w, formatter: formatter{stream: true, indent: true, prefix: prefix, step: indent}}
+}
+
+// Write satisfies the io.Writer interface.
+func (w *FormatWriter) Write(b []byte) (int, error) {
+	w.normalize = w.NormalizeEscapes
+	w.buf = w.format(w.buf[:0], b)
+
+	if _, err := w.w.Write(w.buf); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// formatter implements the state machine used to reformat JSON content, the
+// state is kept between calls to format so the input can be split in chunks
+// at any byte offset.
+type formatter struct {
+	indent    bool
+	stream    bool
+	normalize bool
+	prefix    string
+	step      string
+
+	depth   int
+	str     []byte // content of the current string when normalizing
+	inStr   bool   // within a string
+	escaped bool   // after a backslash within a string
+	scalar  bool   // within a number or literal
+	open    bool   // after an opening delimiter, before the next token
+	sep     bool   // after a top-level value
+}
+
+func (f *formatter) format(dst []byte, src []byte) []byte {
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+
+		if f.inStr {
+			dst, i = f.formatString(dst, src, i)
+			continue
+		}
+
+		if f.scalar && !isScalarByte(c) {
+			f.scalar = false
+			f.endValue()
+		}
+
+		switch c {
+		case ' ', '\t', '\r':
+
+		case '\n':
+			if f.stream && f.sep && f.depth == 0 {
+				dst = append(dst, '\n')
+				f.sep = false
+			}
+
+		case '{', '[':
+			dst = f.beforeValue(dst)
+			dst = append(dst, c)
+			f.depth++
+			f.open = true
+
+		case '}', ']':
+			if f.depth--; f.open {
+				f.open = false
+			} else if f.indent {
+				dst = f.newline(dst)
+			}
+			dst = append(dst, c)
+			f.endValue()
+
+		case ',':
+			dst = append(dst, ',')
+			if f.indent {
+				dst = f.newline(dst)
+			}
+
+		case ':':
+			dst = append(dst, ':')
+			if f.indent {
+				dst = append(dst, ' ')
+			}
+
+		case '"':
+			dst = f.beforeValue(dst)
+			dst = append(dst, '"')
+			f.inStr = true
+			f.str = f.str[:0]
+
+		default:
+			if !f.scalar {
+				dst = f.beforeValue(dst)
+				f.scalar = true
+			}
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+// formatString processes the content of a string starting at src[i], it
+// returns the offset of the last byte that it consumed.
+func (f *formatter) formatString(dst []byte, src []byte, i int) ([]byte, int) {
+	for ; i < len(src); i++ {
+		c := src[i]
+
+		switch {
+		case f.escaped:
+			f.escaped = false
+
+		case c == '\\':
+			f.escaped = true
+
+		case c == '"':
+			f.inStr = false
+			if f.normalize {
+				dst = appendNormalized(dst, f.str)
+			}
+			dst = append(dst, '"')
+			f.endValue()
+			return dst, i
+
+		default:
+			// Fast path for the bytes that don't need special handling.
+			j := i + 1
+			for j < len(src) && src[j] != '"' && src[j] != '\\' {
+				j++
+			}
+			if f.normalize {
+				f.str = append(f.str, src[i:j]...)
+			} else {
+				dst = append(dst, src[i:j]...)
+			}
+			i = j - 1
+			continue
+		}
+
+		if f.normalize {
+			f.str = append(f.str, c)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return dst, i
+}
+
+func (f *formatter) beforeValue(dst []byte) []byte {
+	if f.open {
+		f.open = false
+		if f.indent {
+			dst = f.newline(dst)
+		}
+	}
+	if f.sep {
+		f.sep = false
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+func (f *formatter) endValue() {
+	if f.stream && f.depth == 0 {
+		f.sep = true
+	}
+}
+
+func (f *formatter) newline(dst []byte) []byte {
+	dst = append(dst, '\n')
+	dst = append(dst, f.prefix...)
+	for i := 0; i < f.depth; i++ {
+		dst = append(dst, f.step...)
+	}
+	return dst
+}
+
+func isScalarByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'E'
+}
+
+// appendNormalized appends the escaped version of the raw string content s to
+// dst, if s contains invalid escape sequences it is appended unchanged. Strings
+// are escaped with appendEscapedString rather than WriteEscaped, see the
+// documentation of FormatWriter.NormalizeEscapes.
+func appendNormalized(dst []byte, s []byte) []byte {
+	if bytes.IndexByte(s, '\\') < 0 {
+		return append(dst, s...)
+	}
+
+	u, err := Unescape(s)
+	if err != nil {
+		return append(dst, s...)
+	}
+
+	return appendEscapedString(dst, string(u), false)
+}
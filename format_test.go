@@ -0,0 +1,144 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var formatTests = []string{
+	`null`,
+	`-1.5e10`,
+	`"Hello World!"`,
+	`[]`,
+	`{}`,
+	` [ 1 , 2 , [ ] , { } ] `,
+	"{\n\t\"a\": \"b c\",\n\t\"d\": [true, false, null],\n\t\"e\": {\"f\": {\"g\": \"h\\\"{}[],:\"}}\n}",
+	longJSONDocument,
+}
+
+func TestCompact(t *testing.T) {
+	for _, test := range formatTests {
+		expect := &bytes.Buffer{}
+		json.Compact(expect, []byte(test))
+
+		if b, err := Compact([]byte("prefix:"), []byte(test)); err != nil {
+			t.Errorf("%.40q: %s", test, err)
+		} else if string(b) != "prefix:"+expect.String() {
+			t.Errorf("%.40q: invalid compact output:\n%s\n%s", test, expect.String(), string(b))
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	for _, test := range formatTests {
+		expect := &bytes.Buffer{}
+		json.Indent(expect, bytes.TrimSpace([]byte(test)), ">", "  ")
+
+		if b, err := Indent(nil, []byte(test), ">", "  "); err != nil {
+			t.Errorf("%.40q: %s", test, err)
+		} else if string(b) != expect.String() {
+			t.Errorf("%.40q: invalid indented output:\n%s\n%s", test, expect.String(), string(b))
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	if _, err := Compact(nil, []byte(`{"a":}`)); err == nil {
+		t.Error("Compact: expected a syntax error")
+	}
+	if _, err := Indent(nil, []byte(`[1,]`), "", "\t"); err == nil {
+		t.Error("Indent: expected a syntax error")
+	}
+}
+
+func TestFormatWriter(t *testing.T) {
+	for _, test := range formatTests {
+		compact, _ := Compact(nil, []byte(test))
+		indent, _ := Indent(nil, []byte(test), "", "\t")
+
+		for _, size := range []int{1, 2, 3, 7, 64} {
+			b1 := &bytes.Buffer{}
+			b2 := &bytes.Buffer{}
+			w1 := NewCompactWriter(b1)
+			w2 := NewIndentWriter(b2, "", "\t")
+
+			for s := []byte(test); len(s) != 0; {
+				n := size
+				if n > len(s) {
+					n = len(s)
+				}
+				w1.Write(s[:n])
+				w2.Write(s[:n])
+				s = s[n:]
+			}
+
+			if b1.String() != string(compact) {
+				t.Errorf("%.40q: invalid compact output with chunks of %d bytes:\n%s\n%s", test, size, string(compact), b1.String())
+			}
+
+			if b2.String() != string(indent) {
+				t.Errorf("%.40q: invalid indented output with chunks of %d bytes:\n%s\n%s", test, size, string(indent), b2.String())
+			}
+		}
+	}
+}
+
+func TestFormatWriterStream(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"{ }\n{ }\n", "{}\n{}\n"},
+		{"[1] [2]", "[1]\n[2]"},
+		{"1 2\n\n\n3\n", "1\n2\n3\n"},
+		{"\"a\"\t\"b\"", "\"a\"\n\"b\""},
+	}
+
+	for _, test := range tests {
+		b := &bytes.Buffer{}
+		NewCompactWriter(b).Write([]byte(test.in))
+
+		if b.String() != test.out {
+			t.Errorf("%q: invalid output: %q != %q", test.in, test.out, b.String())
+		}
+	}
+}
+
+func TestFormatWriterNormalizeEscapes(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewCompactWriter(b)
+	w.NormalizeEscapes = true
+
+	for _, c := range []byte(`{"A/b": ["\"\té\"", "\x"]}`) {
+		w.Write([]byte{c})
+	}
+
+	if s := b.String(); s != `{"A/b":["\"\té\"","\x"]}` {
+		t.Errorf("invalid output: %s", s)
+	}
+}
+
+func TestFormatWriterNormalizeControlCharacters(t *testing.T) {
+	for c := 0; c != 0x20; c++ {
+		in := fmt.Sprintf(`{"a": "x\u%04x\u000b\/"}`, c)
+		b := &bytes.Buffer{}
+		w := NewCompactWriter(b)
+		w.NormalizeEscapes = true
+		w.Write([]byte(in))
+
+		if !json.Valid(b.Bytes()) {
+			t.Errorf("%s: invalid JSON output: %q", in, b.String())
+		}
+
+		var v1, v2 interface{}
+		json.Unmarshal([]byte(in), &v1)
+		json.Unmarshal(b.Bytes(), &v2)
+
+		if !reflect.DeepEqual(v1, v2) {
+			t.Errorf("%s: the normalized string differs: %q", in, b.String())
+		}
+	}
+}
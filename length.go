@@ -2,11 +2,16 @@ package jutil
 
 import (
 	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
 	"reflect"
 	"strconv"
-	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Lengther can be implemented by a value to override the default length
@@ -16,10 +21,250 @@ type Lengther interface {
 	LengthJSON() int
 }
 
+// OptionsLengther can be implemented by a value to override the default length
+// deduction algorithm implemented by Length and LengthWithOptions. It takes
+// precedence over Lengther.
+type OptionsLengther interface {
+	// LengthJSONWithOptions returns the length of the value once serialized
+	// to JSON by an encoder configured with the given options, or an error
+	// if the value can't be serialized.
+	LengthJSONWithOptions(opts *LengthOptions) (int, error)
+}
+
+var (
+	lengtherType        = reflect.TypeOf((*Lengther)(nil)).Elem()
+	optionsLengtherType = reflect.TypeOf((*OptionsLengther)(nil)).Elem()
+)
+
+// StringEscaping is an enumeration of the rules used to escape strings.
+type StringEscaping int
+
+const (
+	// EscapeStandard is the escaping of the standard json package, control
+	// characters are written as \u00XX sequences (except for the ones that
+	// have a short form), invalid UTF-8 bytes are replaced with U+FFFD and
+	// the U+2028 and U+2029 line separators are escaped.
+	EscapeStandard StringEscaping = iota
+
+	// EscapeLegacy is the escaping implemented by WriteEscaped, which only
+	// uses short escape sequences and leaves other bytes untouched.
+	EscapeLegacy
+)
+
+// BytesEncoding is an enumeration of the representations of byte slices.
+type BytesEncoding int
+
+const (
+	// BytesBase64 represents byte slices as base64 strings.
+	BytesBase64 BytesEncoding = iota
+
+	// BytesArray represents byte slices as arrays of numbers.
+	BytesArray
+
+	// BytesBase64URL represents byte slices as base64 strings with the URL
+	// safe alphabet.
+	BytesBase64URL
+
+	// BytesBase64RawURL represents byte slices as unpadded base64 strings
+	// with the URL safe alphabet.
+	BytesBase64RawURL
+
+	// BytesHex represents byte slices as hexadecimal strings.
+	BytesHex
+)
+
+// TimeFormat is an enumeration of the representations of time values.
+type TimeFormat int
+
+const (
+	// TimeRFC3339Nano represents time values as RFC 3339 strings with
+	// nanosecond precision, which is what their MarshalJSON method does.
+	TimeRFC3339Nano TimeFormat = iota
+
+	// TimeUnix represents time values as the number of seconds elapsed
+	// since the Unix epoch.
+	TimeUnix
+
+	// TimeUnixMilli represents time values as the number of milliseconds
+	// elapsed since the Unix epoch.
+	TimeUnixMilli
+
+	// TimeUnixNano represents time values as the number of nanoseconds
+	// elapsed since the Unix epoch.
+	TimeUnixNano
+)
+
+// LengthOptions configures how LengthWithOptions computes the length of
+// values, each field matches a setting of the encoder that the length is
+// computed for. The zero-value computes lengths that match the output of
+// an Encoder with no options set.
+type LengthOptions struct {
+	// Escaping selects how strings are escaped.
+	Escaping StringEscaping
+
+	// EscapeHTML enables escaping of the <, > and & characters as \u00XX
+	// sequences.
+	EscapeHTML bool
+
+	// EscapeSlash enables escaping of the / character, it's always escaped
+	// with the legacy escaping.
+	EscapeSlash bool
+
+	// NilSliceAsEmpty and NilMapAsEmpty represent nil slices as [] and nil
+	// maps as {} instead of null.
+	NilSliceAsEmpty bool
+	NilMapAsEmpty   bool
+
+	// FloatFormat and FloatPrecision are the arguments passed to
+	// strconv.FormatFloat to represent floating point numbers, the format
+	// used by the standard json package is applied when FloatFormat is zero.
+	FloatFormat    byte
+	FloatPrecision int
+
+	// BytesEncoding selects the representation of byte slices.
+	BytesEncoding BytesEncoding
+
+	// TimeFormat selects the representation of time.Time values.
+	TimeFormat TimeFormat
+
+	// TimeLayout is the layout used to format time.Time values as strings,
+	// it takes precedence over TimeFormat when it's not empty.
+	TimeLayout string
+
+	// ValidateNumbers enables the validation of json.Number values, an error
+	// is returned for the ones which aren't valid JSON numbers, like the
+	// standard json package does.
+	ValidateNumbers bool
+}
+
+var (
+	// StdLengthOptions computes lengths that match the output of the
+	// standard json package, and of the encoders that are compatible with
+	// it (like json-iterator's ConfigCompatibleWithStandardLibrary or
+	// segmentio/encoding/json).
+	StdLengthOptions = LengthOptions{
+		EscapeHTML:      true,
+		ValidateNumbers: true,
+	}
+
+	// LegacyLengthOptions computes lengths that match the behavior of
+	// Length, strings are sized with the legacy escaping of WriteEscaped.
+	LegacyLengthOptions = LengthOptions{
+		Escaping: EscapeLegacy,
+	}
+
+	// EmptyCollectionsLengthOptions computes lengths that match the output of
+	// encoders which represent nil slices and maps as empty collections.
+	EmptyCollectionsLengthOptions = LengthOptions{
+		EscapeHTML:      true,
+		NilSliceAsEmpty: true,
+		NilMapAsEmpty:   true,
+		ValidateNumbers: true,
+	}
+)
+
+// lenString returns the length of s once quoted and escaped according to the
+// options.
+func (o *LengthOptions) lenString(s string) int {
+	if o.Escaping == EscapeLegacy && !o.EscapeHTML {
+		return jsonLenString(s)
+	}
+	return jsonLenStringTable(s, &lengthEscapeTables[o.escapeTableIndex()], o.Escaping == EscapeStandard)
+}
+
+func (o *LengthOptions) escapeTableIndex() (i int) {
+	if o.Escaping == EscapeLegacy {
+		i |= 1
+	}
+	if o.EscapeHTML {
+		i |= 2
+	}
+	if o.EscapeSlash {
+		i |= 4
+	}
+	return
+}
+
+// lengthEscapeTables holds the number of bytes added by escaping each ASCII
+// character, for each combination of the escaping options (see the
+// escapeTableIndex method).
+var lengthEscapeTables [8][128]uint8
+
+func init() {
+	for i := range lengthEscapeTables {
+		t := &lengthEscapeTables[i]
+
+		if i&1 == 0 {
+			for c := 0; c != 0x20; c++ {
+				t[c] = 5
+			}
+			for _, c := range "\"\\\b\f\n\r\t" {
+				t[c] = 1
+			}
+		} else {
+			for _, c := range "\"\\/\b\f\n\r\t\v" {
+				t[c] = 1
+			}
+		}
+
+		if i&2 != 0 {
+			for _, c := range "<>&" {
+				t[c] = 5
+			}
+		}
+
+		if i&4 != 0 {
+			t['/'] = 1
+		}
+	}
+}
+
 // Length computes the length of the JSON representation of a value of
 // arbitrary type, it's ~10x faster than serializing the content with the
 // standard json package and avoid the extra memory allocations.
+//
+// Length uses the LegacyLengthOptions profile, use LengthWithOptions to
+// compute lengths compatible with other encoders.
 func Length(v interface{}) (n int, err error) {
+	return jsonLen(v, &LegacyLengthOptions)
+}
+
+// LengthWithOptions computes the length of the JSON representation of a value
+// of arbitrary type, as it would be serialized by an encoder configured with
+// the given options.
+func LengthWithOptions(v interface{}, opts LengthOptions) (n int, err error) {
+	return jsonLen(v, &opts)
+}
+
+// LengthString returns the length of s once quoted and escaped according to
+// opts.
+func LengthString(s string, opts *LengthOptions) int {
+	return opts.lenString(s)
+}
+
+// LengthInt returns the length of the JSON representation of v.
+func LengthInt(v int64) int {
+	return jsonLenInt(v)
+}
+
+// LengthUint returns the length of the JSON representation of v.
+func LengthUint(v uint64) int {
+	return jsonLenUint(v)
+}
+
+// LengthFloat returns the length of the JSON representation of v, where bits
+// is the size of the floating point type (32 or 64). An error is returned if
+// v is NaN or an infinity.
+func LengthFloat(v float64, bits int, opts *LengthOptions) (int, error) {
+	return jsonLenFloat(v, bits, opts)
+}
+
+// LengthBool returns the length of the JSON representation of v.
+func LengthBool(v bool) int {
+	return jsonLenBool(v)
+}
+
+func jsonLen(v interface{}, o *LengthOptions) (n int, err error) {
 	var b []byte
 
 	if v == nil {
@@ -64,28 +309,77 @@ func Length(v interface{}) (n int, err error) {
 		n = jsonLenUint(uint64(x))
 
 	case float32:
-		n = jsonLenFloat(float64(x))
+		n, err = jsonLenFloat(float64(x), 32, o)
 
 	case float64:
-		n = jsonLenFloat(float64(x))
+		n, err = jsonLenFloat(x, 64, o)
 
 	case string:
-		n = jsonLenString(x)
+		n = o.lenString(x)
 
 	case []byte:
-		n = jsonLenBytes(x)
+		if x == nil && !o.NilSliceAsEmpty {
+			n = jsonLenNull()
+		} else {
+			n = jsonLenBytes(x, o)
+		}
 
 	case map[string]interface{}:
-		n, err = jsonLenMapStringInterface(x)
+		n, err = jsonLenMapStringInterface(x, o)
 
 	case []interface{}:
-		n, err = jsonLenSliceInterface(x)
+		n, err = jsonLenSliceInterface(x, o)
+
+	// Fast path for the common typed collections, they would otherwise go
+	// through reflection and box each element into an interface value.
+	case []string:
+		n = jsonLenSliceString(x, o)
+
+	case []int:
+		n = jsonLenSliceInt(x, o)
+
+	case []int64:
+		n = jsonLenSliceInt64(x, o)
+
+	case []float64:
+		n, err = jsonLenSliceFloat64(x, o)
+
+	case []bool:
+		n = jsonLenSliceBool(x, o)
+
+	case map[string]string:
+		n = jsonLenMapStringString(x, o)
+
+	case map[string]int:
+		n = jsonLenMapStringInt(x, o)
+
+	case map[string]float64:
+		n, err = jsonLenMapStringFloat64(x, o)
+
+	case time.Time:
+		n, err = jsonLenTime(x, o)
+
+	case *big.Int:
+		n = jsonLenBigInt(x)
+
+	case *big.Float:
+		n = jsonLenBigFloat(x)
+
+	case *big.Rat:
+		n = jsonLenBigRat(x)
+
+	case OptionsLengther:
+		if isNilPointer(x) {
+			n = jsonLenNull()
+		} else {
+			n, err = x.LengthJSONWithOptions(o)
+		}
 
 	case Lengther:
 		n = x.LengthJSON()
 
 	case json.Number:
-		n = len(x)
+		n, err = jsonLenNumber(x, o)
 
 	case json.Marshaler:
 		if b, err = x.MarshalJSON(); err == nil {
@@ -94,17 +388,17 @@ func Length(v interface{}) (n int, err error) {
 
 	case encoding.TextMarshaler:
 		if b, err = x.MarshalText(); err == nil {
-			n = jsonLenString(string(b))
+			n = o.lenString(string(b))
 		}
 
 	default:
-		n, err = jsonLenV(reflect.ValueOf(v))
+		n, err = jsonLenV(reflect.ValueOf(v), o)
 	}
 
 	return
 }
 
-func jsonLenV(v reflect.Value) (n int, err error) {
+func jsonLenV(v reflect.Value, o *LengthOptions) (n int, err error) {
 	if !v.IsValid() {
 		err = &json.UnsupportedValueError{Value: v, Str: "the value is invalid"}
 		return
@@ -112,16 +406,18 @@ func jsonLenV(v reflect.Value) (n int, err error) {
 
 	switch t := v.Type(); t.Kind() {
 	case reflect.Struct:
-		n, err = jsonLenStruct(t, v)
+		n, err = jsonLenStruct(t, v, o)
 
 	case reflect.Map:
-		n, err = jsonLenMap(v)
+		n, err = jsonLenMap(v, o)
 
 	case reflect.Slice:
-		if t.Elem().Kind() == reflect.Uint8 {
-			n = jsonLenBytes(v.Bytes()) // []byte
+		if v.IsNil() && !o.NilSliceAsEmpty {
+			n = jsonLenNull()
+		} else if t.Elem().Kind() == reflect.Uint8 && !isMarshalerType(reflect.PtrTo(t.Elem())) {
+			n = jsonLenBytes(v.Bytes(), o) // []byte
 		} else {
-			n, err = jsonLenArray(v)
+			n, err = jsonLenArray(v, o)
 		}
 
 	case reflect.Ptr, reflect.Interface:
@@ -133,7 +429,7 @@ func jsonLenV(v reflect.Value) (n int, err error) {
 				err = fmt.Errorf("reflect: cannot call Interface on %v", elem)
 				return
 			}
-			n, err = Length(elem.Interface())
+			n, err = jsonLenValue(elem, o)
 		}
 
 	case reflect.Bool:
@@ -145,14 +441,17 @@ func jsonLenV(v reflect.Value) (n int, err error) {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		n = jsonLenUint(v.Uint())
 
-	case reflect.Float32, reflect.Float64:
-		n = jsonLenFloat(v.Float())
+	case reflect.Float32:
+		n, err = jsonLenFloat(v.Float(), 32, o)
+
+	case reflect.Float64:
+		n, err = jsonLenFloat(v.Float(), 64, o)
 
 	case reflect.String:
-		n = jsonLenString(v.String())
+		n = o.lenString(v.String())
 
 	case reflect.Array:
-		n, err = jsonLenArray(v)
+		n, err = jsonLenArray(v, o)
 
 	default:
 		err = &json.UnsupportedTypeError{Type: t}
@@ -161,6 +460,42 @@ func jsonLenV(v reflect.Value) (n int, err error) {
 	return
 }
 
+// jsonLenValue computes the length of a value that was obtained through
+// reflection, if it is addressable the methods of its pointer type are used
+// like the standard json package does.
+func jsonLenValue(v reflect.Value, o *LengthOptions) (n int, err error) {
+	// These types have dedicated cases in jsonLen, time values must not be
+	// taken by address or their MarshalJSON method would be used instead.
+	switch v.Type() {
+	case timeType:
+		if v.CanInterface() {
+			return jsonLenTime(v.Interface().(time.Time), o)
+		}
+	case bigIntType, bigFloatType, bigRatType:
+		if v.CanAddr() && v.CanInterface() {
+			return jsonLen(v.Addr().Interface(), o)
+		}
+	}
+
+	if v.CanAddr() {
+		p := v.Addr()
+
+		if isLengthMethodsType(p.Type()) {
+			return jsonLen(p.Interface(), o)
+		}
+
+		// Structs and arrays are walked with the addressable value so the
+		// pointer methods of their fields and elements are also used.
+		switch v.Kind() {
+		case reflect.Struct, reflect.Array:
+			if !isLengthMethodsType(v.Type()) {
+				return jsonLenV(v, o)
+			}
+		}
+	}
+	return jsonLen(v.Interface(), o)
+}
+
 func jsonLenNull() (n int) {
 	return 4
 }
@@ -173,79 +508,194 @@ func jsonLenBool(v bool) (n int) {
 }
 
 func jsonLenInt(v int64) (n int) {
-	if v == 0 {
-		return 1
-	}
 	if v < 0 {
-		n++
-	}
-	for v != 0 {
-		v /= 10
-		n++
+		if v == math.MinInt64 {
+			return len("-9223372036854775808") // -v would overflow
+		}
+		return 1 + jsonLenUint(uint64(-v))
 	}
-	return
+	return jsonLenUint(uint64(v))
 }
 
 func jsonLenUint(v uint64) (n int) {
-	if v == 0 {
-		return 1
-	}
-	for v != 0 {
-		v /= 10
-		n++
+	// 1233/4096 is an approximation of log10(2), it gives the number of digits
+	// of the values with the same bit length minus one, or minus two for those
+	// below the next power of ten, which the subtraction borrow corrects.
+	n = (bits.Len64(v) * 1233) >> 12
+	_, borrow := bits.Sub64(v, pow10[n], 0)
+	return n + 1 - int(borrow)
+}
+
+// jsonLenNumber computes the length of a json.Number, the empty number is
+// written as 0.
+func jsonLenNumber(v json.Number, o *LengthOptions) (n int, err error) {
+	switch {
+	case len(v) == 0:
+		n = 1
+	case o.ValidateNumbers && !isValidNumber(string(v)):
+		err = fmt.Errorf("json: invalid number literal %q", string(v))
+	default:
+		n = len(v)
 	}
 	return
 }
 
-func jsonLenFloat(v float64) (n int) {
+func jsonLenFloat(v float64, bits int, o *LengthOptions) (n int, err error) {
 	var b [32]byte
-	return len(strconv.AppendFloat(b[:0], v, 'g', -1, 64))
+	var s []byte
+
+	if o.FloatFormat == 0 {
+		s, err = appendFloat(b[:0], v, bits)
+	} else if math.IsNaN(v) || math.IsInf(v, 0) {
+		s, err = appendFloat(b[:0], v, bits) // reports the error
+	} else {
+		s = strconv.AppendFloat(b[:0], v, o.FloatFormat, o.FloatPrecision, bits)
+	}
+
+	return len(s), err
 }
 
 func jsonLenString(s string) (n int) {
-	if len(s) < 100 {
-		// This is an optimization for short strings, most of them will not
-		// contain any escaped bytes and performances won't suffer from checking
-		// the string a couple of times because it will fit into a couple of
-		// cachelines.
-		const escapedBytes = "/\"\\\n\t\r\v\b\f"
-
-		switch len(s) {
-		case 0:
-		case 1:
-			for i := range escapedBytes {
-				if s[0] == escapedBytes[i] {
-					goto slowPath
-				}
-			}
-		default:
-			for i := range escapedBytes {
-				if strings.IndexByte(s, escapedBytes[i]) >= 0 {
-					goto slowPath
-				}
-			}
+	for {
+		i := escapeIndexString(s)
+		if i < 0 {
+			break
+		}
+		n += i + 2
+		s = s[i+1:]
+	}
+	return n + 2 + len(s)
+}
+
+// jsonLenStringTable returns the length of s once quoted, where t gives the
+// number of bytes added by escaping ASCII characters. When validate is true
+// invalid UTF-8 bytes are counted as U+FFFD and the U+2028 and U+2029 line
+// separators as escape sequences.
+func jsonLenStringTable(s string, t *[128]uint8, validate bool) (n int) {
+	n = len(s) + 2
+
+	for i := 0; i != len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			n += int(t[c])
+			i++
+			continue
 		}
 
-		return 2 + len(s)
+		if !validate {
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			n += 2 // U+FFFD is 3 bytes long
+		case r == '\u2028' || r == '\u2029':
+			n += 3 // \u2028 is 6 bytes long
+		}
+
+		i += size
 	}
 
-slowPath:
-	for i := range s {
-		switch s[i] {
-		case '\n', '\t', '\r', '\v', '\b', '\f', '\\', '/', '"':
-			n++
+	return
+}
+
+func jsonLenBytes(b []byte, o *LengthOptions) (n int) {
+	switch o.BytesEncoding {
+	case BytesArray:
+		for _, c := range b {
+			n += jsonLenUint(uint64(c))
 		}
+		return n + jsonLenSeparators(len(b)) + 2
+
+	case BytesBase64RawURL:
+		return base64.RawURLEncoding.EncodedLen(len(b)) + 2
+
+	case BytesHex:
+		return 2*len(b) + 2
+
+	default: // BytesBase64, BytesBase64URL
+		return base64.StdEncoding.EncodedLen(len(b)) + 2
 	}
+}
 
-	return n + 2 + len(s)
+// isLengthMethodsType returns true if values of t provide their own length or
+// representation.
+func isLengthMethodsType(t reflect.Type) bool {
+	return t.Implements(optionsLengtherType) || t.Implements(lengtherType) || isMarshalerType(t)
+}
+
+// isMarshalerType returns true if t implements one of the interfaces that
+// make the json package use a custom representation for values of t, byte
+// slices with such element types are represented as arrays.
+func isMarshalerType(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
+
+// jsonLenTime computes the length of a time value, which is serialized with
+// its MarshalJSON method unless a layout or another format is configured.
+func jsonLenTime(t time.Time, o *LengthOptions) (n int, err error) {
+	if len(o.TimeLayout) != 0 {
+		return o.lenString(t.Format(o.TimeLayout)), nil
+	}
+
+	switch o.TimeFormat {
+	case TimeUnix:
+		return jsonLenInt(t.Unix()), nil
+	case TimeUnixMilli:
+		return jsonLenInt(t.UnixMilli()), nil
+	case TimeUnixNano:
+		return jsonLenInt(t.UnixNano()), nil
+	}
+
+	if n = jsonLenRFC3339Nano(t); n != 0 {
+		return
+	}
+
+	var b []byte
+
+	if b, err = t.MarshalJSON(); err == nil {
+		n = len(b)
+	}
+
+	return
 }
 
-func jsonLenBytes(b []byte) (n int) {
-	// The standard json package uses base64 encoding for byte slices...
-	return 2 + ((len(b) * 4) / 3)
+// jsonLenRFC3339Nano computes the length of the quoted RFC 3339 representation
+// of t with nanosecond precision, without formatting it. Zero is returned for
+// the values that MarshalJSON may refuse to serialize or format differently.
+func jsonLenRFC3339Nano(t time.Time) (n int) {
+	if y := t.Year(); y < 0 || y > 9999 {
+		return 0
+	}
+
+	n = len(`"2006-01-02T15:04:05"`)
+
+	// The fractional part is omitted if it's zero, trailing zeros are trimmed.
+	if ns := t.Nanosecond(); ns != 0 {
+		n += len(".000000000")
+		for ns%10 == 0 {
+			ns /= 10
+			n--
+		}
+	}
+
+	switch _, offset := t.Zone(); {
+	case offset == 0:
+		n += len("Z")
+	case offset%60 != 0 || offset <= -24*3600 || offset >= 24*3600:
+		return 0
+	default:
+		n += len("+07:00")
+	}
+
+	return
 }
 
-func jsonLenArray(v reflect.Value) (n int, err error) {
+func jsonLenArray(v reflect.Value, o *LengthOptions) (n int, err error) {
 	var c int
 
 	for i, j := 0, v.Len(); i != j; i++ {
@@ -258,7 +708,7 @@ func jsonLenArray(v reflect.Value) (n int, err error) {
 			err = fmt.Errorf("reflect: cannot call Interface on value %v", elem)
 			return
 		}
-		if c, err = Length(elem.Interface()); err != nil {
+		if c, err = jsonLenValue(elem, o); err != nil {
 			return
 		}
 
@@ -269,10 +719,14 @@ func jsonLenArray(v reflect.Value) (n int, err error) {
 	return
 }
 
-func jsonLenMap(v reflect.Value) (n int, err error) {
+func jsonLenMap(v reflect.Value, o *LengthOptions) (n int, err error) {
 	var c1 int
 	var c2 int
 
+	if v.IsNil() && !o.NilMapAsEmpty {
+		return jsonLenNull(), nil
+	}
+
 	for i, k := range v.MapKeys() {
 		if !k.CanInterface() {
 			err = fmt.Errorf("reflect: cannot call Interface on value %v", k)
@@ -283,11 +737,11 @@ func jsonLenMap(v reflect.Value) (n int, err error) {
 			n++
 		}
 
-		if c1, err = Length(k.Interface()); err != nil {
+		if c1, err = jsonLenMapKey(k, o); err != nil {
 			return
 		}
 
-		if c2, err = Length(v.MapIndex(k).Interface()); err != nil {
+		if c2, err = jsonLen(v.MapIndex(k).Interface(), o); err != nil {
 			return
 		}
 
@@ -298,14 +752,46 @@ func jsonLenMap(v reflect.Value) (n int, err error) {
 	return
 }
 
-func jsonLenStruct(t reflect.Type, v reflect.Value) (n int, err error) {
+// jsonLenMapKey computes the length of a map key, keys are always serialized
+// as strings.
+func jsonLenMapKey(k reflect.Value, o *LengthOptions) (n int, err error) {
+	if k.Kind() == reflect.String {
+		return o.lenString(k.String()), nil
+	}
+
+	if k.CanInterface() {
+		if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+			if k.Kind() == reflect.Ptr && k.IsNil() {
+				return o.lenString(""), nil // like resolveMapKey
+			}
+			var b []byte
+			if b, err = m.MarshalText(); err == nil {
+				n = o.lenString(string(b))
+			}
+			return
+		}
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = jsonLenInt(k.Int()) + 2
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = jsonLenUint(k.Uint()) + 2
+	default:
+		err = &json.UnsupportedTypeError{Type: k.Type()}
+	}
+
+	return
+}
+
+func jsonLenStruct(t reflect.Type, v reflect.Value, o *LengthOptions) (n int, err error) {
 	var c int
 	var s = LookupStruct(t)
 
 	for _, f := range s {
 		fv := v.FieldByIndex(f.Index)
 
-		if f.Omitempty && isEmptyValue(fv) {
+		if f.Omit(fv) {
 			continue
 		}
 
@@ -313,7 +799,7 @@ func jsonLenStruct(t reflect.Type, v reflect.Value) (n int, err error) {
 			err = fmt.Errorf("reflect: cannot call Interface on %v", fv)
 			return
 		}
-		if c, err = Length(fv.Interface()); err != nil {
+		if c, err = jsonLenValue(fv, o); err != nil {
 			return
 		}
 
@@ -321,18 +807,22 @@ func jsonLenStruct(t reflect.Type, v reflect.Value) (n int, err error) {
 			n++
 		}
 
-		n += jsonLenString(f.Name) + c + 1
+		n += o.lenString(f.Name) + c + 1
 	}
 
 	n += 2
 	return
 }
 
-func jsonLenSliceInterface(s []interface{}) (n int, err error) {
+func jsonLenSliceInterface(s []interface{}, o *LengthOptions) (n int, err error) {
 	var c int
 
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull(), nil
+	}
+
 	for _, v := range s {
-		if c, err = Length(v); err != nil {
+		if c, err = jsonLen(v, o); err != nil {
 			return
 		}
 		n += c
@@ -346,14 +836,18 @@ func jsonLenSliceInterface(s []interface{}) (n int, err error) {
 	return
 }
 
-func jsonLenMapStringInterface(m map[string]interface{}) (n int, err error) {
+func jsonLenMapStringInterface(m map[string]interface{}, o *LengthOptions) (n int, err error) {
 	var c int
 
+	if m == nil && !o.NilMapAsEmpty {
+		return jsonLenNull(), nil
+	}
+
 	for k, v := range m {
-		if c, err = Length(v); err != nil {
+		if c, err = jsonLen(v, o); err != nil {
 			return
 		}
-		n += jsonLenString(k) + c + 1
+		n += o.lenString(k) + c + 1
 	}
 
 	if c = len(m); c > 1 {
@@ -363,3 +857,129 @@ func jsonLenMapStringInterface(m map[string]interface{}) (n int, err error) {
 	n += 2
 	return
 }
+
+func jsonLenSliceString(s []string, o *LengthOptions) (n int) {
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull()
+	}
+	for _, v := range s {
+		n += o.lenString(v)
+	}
+	return n + jsonLenSeparators(len(s)) + 2
+}
+
+func jsonLenSliceInt(s []int, o *LengthOptions) (n int) {
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull()
+	}
+	for _, v := range s {
+		n += jsonLenInt(int64(v))
+	}
+	return n + jsonLenSeparators(len(s)) + 2
+}
+
+func jsonLenSliceInt64(s []int64, o *LengthOptions) (n int) {
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull()
+	}
+	for _, v := range s {
+		n += jsonLenInt(v)
+	}
+	return n + jsonLenSeparators(len(s)) + 2
+}
+
+func jsonLenSliceFloat64(s []float64, o *LengthOptions) (n int, err error) {
+	var c int
+
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull(), nil
+	}
+
+	for _, v := range s {
+		if c, err = jsonLenFloat(v, 64, o); err != nil {
+			return
+		}
+		n += c
+	}
+
+	return n + jsonLenSeparators(len(s)) + 2, nil
+}
+
+func jsonLenSliceBool(s []bool, o *LengthOptions) (n int) {
+	if s == nil && !o.NilSliceAsEmpty {
+		return jsonLenNull()
+	}
+	for _, v := range s {
+		n += jsonLenBool(v)
+	}
+	return n + jsonLenSeparators(len(s)) + 2
+}
+
+func jsonLenMapStringString(m map[string]string, o *LengthOptions) (n int) {
+	if m == nil && !o.NilMapAsEmpty {
+		return jsonLenNull()
+	}
+	for k, v := range m {
+		n += o.lenString(k) + o.lenString(v) + 1
+	}
+	return n + jsonLenSeparators(len(m)) + 2
+}
+
+func jsonLenMapStringInt(m map[string]int, o *LengthOptions) (n int) {
+	if m == nil && !o.NilMapAsEmpty {
+		return jsonLenNull()
+	}
+	for k, v := range m {
+		n += o.lenString(k) + jsonLenInt(int64(v)) + 1
+	}
+	return n + jsonLenSeparators(len(m)) + 2
+}
+
+func jsonLenMapStringFloat64(m map[string]float64, o *LengthOptions) (n int, err error) {
+	var c int
+
+	if m == nil && !o.NilMapAsEmpty {
+		return jsonLenNull(), nil
+	}
+
+	for k, v := range m {
+		if c, err = jsonLenFloat(v, 64, o); err != nil {
+			return
+		}
+		n += o.lenString(k) + c + 1
+	}
+
+	return n + jsonLenSeparators(len(m)) + 2, nil
+}
+
+func jsonLenSeparators(n int) int {
+	if n > 1 {
+		return n - 1
+	}
+	return 0
+}
+
+// pow10 is the table of powers of ten used to compute the length of integers,
+// the first entry is zero instead of one so that zero is counted as one digit.
+var pow10 = [...]uint64{
+	0,
+	1e1,
+	1e2,
+	1e3,
+	1e4,
+	1e5,
+	1e6,
+	1e7,
+	1e8,
+	1e9,
+	1e10,
+	1e11,
+	1e12,
+	1e13,
+	1e14,
+	1e15,
+	1e16,
+	1e17,
+	1e18,
+	1e19,
+}
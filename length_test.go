@@ -1,7 +1,13 @@
 package jutil
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
 	"testing"
 	"time"
 
@@ -23,6 +29,14 @@ func TestLength(t *testing.T) {
 		-1,
 		-42,
 		0.1234,
+		1e20,
+		1e21,
+		1e-6,
+		1e-7,
+		-1.5e-10,
+		float32(0.1),
+		float32(3.4e38),
+		float32(1e-7),
 
 		int(0),
 		int8(10),
@@ -52,10 +66,21 @@ func TestLength(t *testing.T) {
 		[]int{},
 		[]int{1, 2, 3},
 		[]string{"hello", "world"},
+		[]string{"Hello\nWorld!"},
 		[]interface{}{nil, true, 42, "hey!"},
+		[]int64{},
+		[]int64{-1, 0, 1 << 40},
+		[]float64{},
+		[]float64{0, 0.5, -1.25},
+		[]bool{},
+		[]bool{true, false},
 
 		map[string]string{},
+		map[string]string{"A": "1", "B": "2", "C\t": "\"3\""},
 		map[string]int{"answer": 42},
+		map[string]int{"A": -1, "B": 0, "C": 1},
+		map[string]float64{},
+		map[string]float64{"pi": 3.14, "e": 2.72},
 		map[string]interface{}{
 			"A": nil,
 			"B": true,
@@ -108,6 +133,451 @@ func TestLength(t *testing.T) {
 	}
 }
 
+func TestLengthWithOptions(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		"Hello World!",
+		"<a href=\"/\">&amp;</a>",
+		"\x00\x01\x1f\b\f\n\r\t\v\x7f",
+		"\u2028\u2029",
+		"\xff\xc3(invalid)",
+		"héllo wörld",
+		[]byte("abc"),
+		[]string(nil),
+		[]interface{}(nil),
+		map[string]interface{}(nil),
+		map[string]int(nil),
+		[]struct{}(nil),
+		map[int]string{-1: "a", 42: "b"},
+		map[uint8]bool{1: true},
+		map[*net.IP]int{nil: 1},
+		map[*net.IP]int{{127, 0, 0, 1}: 2},
+		map[string][]int{"<>": nil, "a": {1, 2}},
+		[]float64{0.1, 1e21, -1e-7},
+		time.Date(2016, 11, 3, 10, 2, 0, 123, time.UTC),
+		struct {
+			A string `json:"a&b"`
+			B []int
+		}{A: "/"},
+	}
+
+	for _, test := range tests {
+		b, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := LengthWithOptions(test, StdLengthOptions); err != nil {
+			t.Errorf("%#v => %s", test, err)
+		} else if n != len(b) {
+			t.Errorf("%#v => %d != %d (%s)", test, n, len(b), string(b))
+		}
+
+		e := Encoder{}
+		if b, err = e.Marshal(test); err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := LengthWithOptions(test, LengthOptions{}); err != nil {
+			t.Errorf("%#v => %s", test, err)
+		} else if n != len(b) {
+			t.Errorf("%#v => %d != %d (%s)", test, n, len(b), string(b))
+		}
+	}
+}
+
+func TestLengthNilCollections(t *testing.T) {
+	type namedSlice []uint
+	type namedMap map[string]uint
+
+	tests := []struct {
+		value interface{}
+		empty interface{}
+		out   string
+	}{
+		{[]interface{}(nil), []interface{}{}, `[]`},
+		{map[string]interface{}(nil), map[string]interface{}{}, `{}`},
+		{[]string(nil), []string{}, `[]`},
+		{[]int(nil), []int{}, `[]`},
+		{[]int64(nil), []int64{}, `[]`},
+		{[]float64(nil), []float64{}, `[]`},
+		{[]bool(nil), []bool{}, `[]`},
+		{map[string]string(nil), map[string]string{}, `{}`},
+		{map[string]int(nil), map[string]int{}, `{}`},
+		{map[string]float64(nil), map[string]float64{}, `{}`},
+		{[]byte(nil), []byte{}, `""`},
+		{namedSlice(nil), namedSlice{}, `[]`},
+		{namedMap(nil), namedMap{}, `{}`},
+		{[]json.RawMessage(nil), []json.RawMessage{}, `[]`},
+		{map[int]bool(nil), map[int]bool{}, `{}`},
+		{(*OrderedMap)(nil), &OrderedMap{}, `null`},
+	}
+
+	for _, test := range tests {
+		for _, v := range []interface{}{test.value, test.empty} {
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := Length(v); err != nil {
+				t.Errorf("%#v => %s", v, err)
+			} else if n != len(b) {
+				t.Errorf("%#v => %d != %d (%s)", v, n, len(b), string(b))
+			}
+
+			// Nil collections nested in structs.
+			s := struct{ V interface{} }{v}
+			if b, err = json.Marshal(s); err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := Length(s); err != nil {
+				t.Errorf("%#v => %s", s, err)
+			} else if n != len(b) {
+				t.Errorf("%#v => %d != %d (%s)", s, n, len(b), string(b))
+			}
+		}
+
+		if n, err := LengthWithOptions(test.value, EmptyCollectionsLengthOptions); err != nil {
+			t.Errorf("%#v => %s", test.value, err)
+		} else if n != len(test.out) {
+			t.Errorf("%#v => %d != %d (%s)", test.value, n, len(test.out), test.out)
+		}
+	}
+}
+
+type lengthTextByte byte
+
+func (b lengthTextByte) MarshalText() ([]byte, error) {
+	return []byte{'x', byte(b)}, nil
+}
+
+func TestLengthBytes(t *testing.T) {
+	type namedBytes []byte
+
+	for i := 0; i != 10; i++ {
+		b := []byte("0123456789"[:i])
+		m := make([]lengthTextByte, i)
+		for j := range m {
+			m[j] = lengthTextByte(b[j])
+		}
+
+		for _, v := range []interface{}{b, namedBytes(b), [3]byte{1, 2, 3}, m} {
+			j, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := Length(v); err != nil {
+				t.Errorf("%#v => %s", v, err)
+			} else if n != len(j) {
+				t.Errorf("%#v => %d != %d (%s)", v, n, len(j), string(j))
+			}
+		}
+
+		tests := []struct {
+			encoding BytesEncoding
+			expect   string
+		}{
+			{BytesBase64URL, base64.URLEncoding.EncodeToString(b)},
+			{BytesBase64RawURL, base64.RawURLEncoding.EncodeToString(b)},
+			{BytesHex, fmt.Sprintf("%x", b)},
+		}
+
+		for _, test := range tests {
+			if n, err := LengthWithOptions(b, LengthOptions{BytesEncoding: test.encoding}); err != nil {
+				t.Errorf("%q => %s", b, err)
+			} else if n != len(test.expect)+2 {
+				t.Errorf("%q => %d != %d (%s)", b, n, len(test.expect)+2, test.expect)
+			}
+		}
+	}
+}
+
+type lengthPtrMarshaler struct{ A int }
+
+func (*lengthPtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"marshaler"`), nil
+}
+
+type lengthPtrTextMarshaler struct{ A int }
+
+func (*lengthPtrTextMarshaler) MarshalText() ([]byte, error) {
+	return []byte(`text<marshaler>`), nil
+}
+
+type lengthPtrLengther struct{ A int }
+
+func (*lengthPtrLengther) LengthJSON() int {
+	return 42
+}
+
+func TestLengthPointerMethods(t *testing.T) {
+	type fields struct {
+		M lengthPtrMarshaler
+		T lengthPtrTextMarshaler
+		S []lengthPtrMarshaler
+		A [2]lengthPtrTextMarshaler
+		N struct{ M lengthPtrMarshaler }
+	}
+
+	v := fields{S: []lengthPtrMarshaler{{}, {}}}
+
+	tests := []interface{}{
+		&v,
+		[]fields{v},
+		[]lengthPtrTextMarshaler{{}},
+		&[1]lengthPtrMarshaler{},
+		map[string]*fields{"a": &v},
+
+		// Values that aren't addressable use the value methods.
+		v,
+		[1]lengthPtrMarshaler{},
+		map[string]fields{"a": v},
+	}
+
+	for _, test := range tests {
+		b, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := LengthWithOptions(test, StdLengthOptions); err != nil {
+			t.Errorf("%#v => %s", test, err)
+		} else if n != len(b) {
+			t.Errorf("%#v => %d != %d (%s)", test, n, len(b), string(b))
+		}
+	}
+
+	lengthers := []struct {
+		value  interface{}
+		expect int
+	}{
+		{&struct{ L lengthPtrLengther }{}, len(`{"L":}`) + 42},
+		{[]lengthPtrLengther{{}, {}}, len(`[,]`) + 84},
+		{struct{ L lengthPtrLengther }{}, len(`{"L":{"A":0}}`)},
+	}
+
+	for _, test := range lengthers {
+		if n, err := Length(test.value); err != nil {
+			t.Errorf("%#v => %s", test.value, err)
+		} else if n != test.expect {
+			t.Errorf("%#v => %d != %d", test.value, n, test.expect)
+		}
+	}
+}
+
+type lengthOptionsLengther struct{ S string }
+
+func (v *lengthOptionsLengther) LengthJSONWithOptions(o *LengthOptions) (int, error) {
+	if v.S == "error" {
+		return 0, errors.New("error")
+	}
+	return LengthString(v.S, o), nil
+}
+
+func (v *lengthOptionsLengther) LengthJSON() int {
+	return -1
+}
+
+func TestLengthOptionsLengther(t *testing.T) {
+	v := &lengthOptionsLengther{S: "<>"}
+
+	if n, err := LengthWithOptions(v, StdLengthOptions); err != nil {
+		t.Error(err)
+	} else if n != len(`"\u003c\u003e"`) {
+		t.Error("invalid length:", n)
+	}
+
+	if n, err := Length([]lengthOptionsLengther{{S: "a"}}); err != nil {
+		t.Error(err)
+	} else if n != len(`["a"]`) {
+		t.Error("invalid length:", n)
+	}
+
+	if n, err := Length((*lengthOptionsLengther)(nil)); err != nil {
+		t.Error(err)
+	} else if n != len(`null`) {
+		t.Error("invalid length:", n)
+	}
+
+	if _, err := Length(map[string]interface{}{"a": &lengthOptionsLengther{S: "error"}}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestLengthTime(t *testing.T) {
+	zones := []*time.Location{
+		time.UTC,
+		time.FixedZone("", 0),
+		time.FixedZone("", -7*3600),
+		time.FixedZone("", 5*3600+45*60),
+		time.FixedZone("", 3600+30),
+		time.FixedZone("", 23*3600+59*60),
+		time.FixedZone("", -24*3600),
+	}
+
+	times := []time.Time{
+		{},
+		time.Date(2016, 11, 3, 10, 2, 0, 0, time.UTC),
+		time.Date(2016, 11, 3, 10, 2, 0, 100, time.UTC),
+		time.Date(2016, 11, 3, 10, 2, 0, 123456789, time.UTC),
+		time.Date(2016, 11, 3, 10, 2, 0, 120000000, time.UTC),
+		time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Now(),
+	}
+
+	for _, tm := range times {
+		for _, z := range zones {
+			v := tm.In(z)
+			b, err1 := v.MarshalJSON()
+			n, err2 := Length(v)
+
+			if (err1 != nil) != (err2 != nil) {
+				t.Errorf("%s: error mismatch: %v != %v", v, err1, err2)
+			} else if err1 == nil && n != len(b) {
+				t.Errorf("%s: %d != %d (%s)", v, n, len(b), b)
+			}
+		}
+	}
+
+	for _, d := range []time.Duration{0, -1, time.Nanosecond, 12 * time.Hour, math.MinInt64} {
+		b, _ := json.Marshal(d)
+
+		if n, err := Length(d); err != nil {
+			t.Error(err)
+		} else if n != len(b) {
+			t.Errorf("%s: %d != %d (%s)", d, n, len(b), b)
+		}
+	}
+}
+
+func TestLengthTimeOptions(t *testing.T) {
+	v := time.Date(2016, 11, 3, 10, 2, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		value   time.Time
+		options LengthOptions
+		expect  string
+	}{
+		{v, LengthOptions{TimeFormat: TimeUnix}, strconv.FormatInt(v.Unix(), 10)},
+		{v, LengthOptions{TimeFormat: TimeUnixMilli}, strconv.FormatInt(v.UnixMilli(), 10)},
+		{v, LengthOptions{TimeFormat: TimeUnixNano}, strconv.FormatInt(v.UnixNano(), 10)},
+		{v, LengthOptions{TimeFormat: TimeUnix, TimeLayout: time.Kitchen}, `"10:02AM"`},
+		{time.Time{}, LengthOptions{TimeFormat: TimeUnixMilli}, `-62135596800000`},
+	}
+
+	for _, test := range tests {
+		if n, err := LengthWithOptions(test.value, test.options); err != nil {
+			t.Error(err)
+		} else if n != len(test.expect) {
+			t.Errorf("%+v: %d != %d (%s)", test.options, n, len(test.expect), test.expect)
+		}
+	}
+}
+
+func TestLengthTimeOptionsAddressable(t *testing.T) {
+	type withTime struct {
+		T time.Time
+		P *time.Time
+		A [1]time.Time
+	}
+
+	v := time.Date(2016, 11, 3, 10, 2, 0, 123456789, time.UTC)
+
+	for _, options := range []LengthOptions{
+		{TimeFormat: TimeRFC3339Nano},
+		{TimeFormat: TimeUnix},
+		{TimeFormat: TimeUnixMilli},
+		{TimeFormat: TimeUnixNano},
+		{TimeLayout: time.Kitchen},
+	} {
+		value := withTime{T: v, P: &v, A: [1]time.Time{v}}
+
+		expect, err := LengthWithOptions(value, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := LengthWithOptions(&value, options); err != nil {
+			t.Error(err)
+		} else if n != expect {
+			t.Errorf("%+v: %d != %d", options, n, expect)
+		}
+
+		if n, err := LengthWithOptions(&[]withTime{value}, options); err != nil {
+			t.Error(err)
+		} else if n != expect+2 {
+			t.Errorf("%+v: %d != %d", options, n, expect+2)
+		}
+	}
+}
+
+func TestLengthTimeAllocs(t *testing.T) {
+	v := interface{}(time.Now().In(time.FixedZone("", 3600)))
+
+	if n := testing.AllocsPerRun(100, func() { Length(v) }); n != 0 {
+		t.Error("too many memory allocations:", n)
+	}
+}
+
+func FuzzLengthTime(f *testing.F) {
+	f.Add(int64(0), int64(0), 0)
+	f.Add(int64(1478167320), int64(123456789), 3600)
+	f.Add(int64(-62135596801), int64(1), -60)
+
+	f.Fuzz(func(t *testing.T, sec int64, nsec int64, offset int) {
+		v := time.Unix(sec, nsec).In(time.FixedZone("", offset))
+		b, err1 := v.MarshalJSON()
+		n, err2 := Length(v)
+
+		if (err1 != nil) != (err2 != nil) {
+			t.Fatalf("%s: error mismatch: %v != %v", v, err1, err2)
+		}
+		if err1 == nil && n != len(b) {
+			t.Fatalf("%s: %d != %d (%s)", v, len(b), n, b)
+		}
+	})
+}
+
+func TestLengthOptions(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		options LengthOptions
+		expect  string
+	}{
+		{"a/b", LengthOptions{}, `"a/b"`},
+		{"a/b", LengthOptions{EscapeSlash: true}, `"a\/b"`},
+		{"<\n>", LengthOptions{Escaping: EscapeLegacy, EscapeHTML: true}, `"\u003c\n\u003e"`},
+		{"\x00\v", LengthOptions{Escaping: EscapeLegacy}, "\"\x00\\v\""},
+		{[]int(nil), LengthOptions{}, `null`},
+		{[]int(nil), LengthOptions{NilSliceAsEmpty: true}, `[]`},
+		{map[string]bool(nil), LengthOptions{}, `null`},
+		{map[string]bool(nil), LengthOptions{NilMapAsEmpty: true}, `{}`},
+		{1.5, LengthOptions{FloatFormat: 'f', FloatPrecision: 3}, `1.500`},
+		{float32(1e21), LengthOptions{FloatFormat: 'e', FloatPrecision: -1}, `1e+21`},
+		{[]byte{1, 20, 255}, LengthOptions{BytesEncoding: BytesArray}, `[1,20,255]`},
+		{[]byte{}, LengthOptions{BytesEncoding: BytesArray}, `[]`},
+		{time.Date(2016, 11, 3, 0, 0, 0, 0, time.UTC), LengthOptions{TimeLayout: "2006-01-02"}, `"2016-11-03"`},
+	}
+
+	for _, test := range tests {
+		if n, err := LengthWithOptions(test.value, test.options); err != nil {
+			t.Errorf("%#v => %s", test.value, err)
+		} else if n != len(test.expect) {
+			t.Errorf("%#v => %d != %d (%s)", test.value, n, len(test.expect), test.expect)
+		}
+	}
+
+	if _, err := LengthWithOptions(math.NaN(), LengthOptions{FloatFormat: 'f'}); err == nil {
+		t.Error("expected an error for NaN")
+	}
+}
+
 func benchLength(b *testing.B, v interface{}) {
 	for i := 0; i != b.N; i++ {
 		benchLengthFunc(v)
@@ -253,3 +723,258 @@ func BenchmarkLengthStructOmpitemptytNonZero(b *testing.B) {
 		C int `json:",omitempty"`
 	}{1, 2, 3})
 }
+
+func BenchmarkLengthSliceStringZero(b *testing.B) {
+	benchLength(b, []string{})
+}
+
+func BenchmarkLengthSliceStringNonZero(b *testing.B) {
+	benchLength(b, []string{
+		"page", "track", "identify", "group", "alias", "screen", "Hello\nWorld!",
+	})
+}
+
+func BenchmarkLengthSliceIntNonZero(b *testing.B) {
+	benchLength(b, []int{
+		0, 1, -1, 10, 100, 1000, 10000, 100000, 1000000, 10000000,
+	})
+}
+
+func BenchmarkLengthSliceFloat64NonZero(b *testing.B) {
+	benchLength(b, []float64{
+		0, 1, -1, 0.5, 1.25, 3.14159, 1e10, 1e-10,
+	})
+}
+
+func BenchmarkLengthMapStringIntNonZero(b *testing.B) {
+	benchLength(b, map[string]int{
+		"0": 0,
+		"1": 1,
+		"2": 2,
+		"3": 3,
+		"4": 4,
+		"5": 5,
+		"6": 6,
+		"7": 7,
+		"8": 8,
+		"9": 9,
+	})
+}
+
+func BenchmarkLengthAnalyticsPayload(b *testing.B) {
+	benchLength(b, map[string]interface{}{
+		"type":    "track",
+		"event":   "Order Completed",
+		"userId":  "019mr8mf4r",
+		"context": map[string]string{"ip": "8.8.8.8", "locale": "en-US", "userAgent": "Mozilla/5.0"},
+		"traits":  map[string]string{"name": "Peter Gibbons", "email": "peter@example.com", "plan": "premium"},
+		"tags":    []string{"checkout", "mobile", "promo", "returning"},
+		"counts":  map[string]int{"items": 3, "coupons": 1},
+		"prices":  []float64{9.99, 19.99, 4.5},
+	})
+}
+
+func TestLengthIntBoundaries(t *testing.T) {
+	test := func(v int64) {
+		if n, s := jsonLenInt(v), strconv.FormatInt(v, 10); n != len(s) {
+			t.Errorf("jsonLenInt(%s): %d != %d", s, len(s), n)
+		}
+	}
+
+	test(0)
+	test(math.MaxInt64)
+	test(math.MinInt64)
+	test(math.MinInt64 + 1)
+
+	for p := int64(1); p <= math.MaxInt64/10; p *= 10 {
+		for _, v := range []int64{p - 1, p, p + 1, 10*p - 1} {
+			test(v)
+			test(-v)
+		}
+	}
+}
+
+func TestLengthUintBoundaries(t *testing.T) {
+	test := func(v uint64) {
+		if n, s := jsonLenUint(v), strconv.FormatUint(v, 10); n != len(s) {
+			t.Errorf("jsonLenUint(%s): %d != %d", s, len(s), n)
+		}
+	}
+
+	test(0)
+	test(math.MaxUint64)
+
+	for p := uint64(1); ; p *= 10 {
+		test(p - 1)
+		test(p)
+		test(p + 1)
+
+		if p > math.MaxUint64/10 {
+			break
+		}
+	}
+
+	for i := uint(0); i != 64; i++ {
+		test(1 << i)
+		test(1<<i - 1)
+	}
+}
+
+// jsonLenIntLoop and jsonLenUintLoop are the previous implementations of
+// jsonLenInt and jsonLenUint, kept to compare their performance.
+func jsonLenIntLoop(v int64) (n int) {
+	if v == 0 {
+		return 1
+	}
+	if v < 0 {
+		n++
+	}
+	for v != 0 {
+		v /= 10
+		n++
+	}
+	return
+}
+
+func jsonLenUintLoop(v uint64) (n int) {
+	if v == 0 {
+		return 1
+	}
+	for v != 0 {
+		v /= 10
+		n++
+	}
+	return
+}
+
+var benchSink int
+
+var benchInts = [...]int64{0, 7, -42, 1234, 99999, -1234567, 2147483647, 1 << 40, -1 << 50, math.MaxInt64}
+
+func BenchmarkLengthInt(b *testing.B) {
+	n := 0
+	for i := 0; i != b.N; i++ {
+		n += jsonLenInt(benchInts[i%len(benchInts)])
+	}
+	benchSink = n
+}
+
+func BenchmarkLengthIntLoop(b *testing.B) {
+	n := 0
+	for i := 0; i != b.N; i++ {
+		n += jsonLenIntLoop(benchInts[i%len(benchInts)])
+	}
+	benchSink = n
+}
+
+func BenchmarkLengthUint(b *testing.B) {
+	n := 0
+	for i := 0; i != b.N; i++ {
+		n += jsonLenUint(uint64(benchInts[i%len(benchInts)]))
+	}
+	benchSink = n
+}
+
+func BenchmarkLengthUintLoop(b *testing.B) {
+	n := 0
+	for i := 0; i != b.N; i++ {
+		n += jsonLenUintLoop(uint64(benchInts[i%len(benchInts)]))
+	}
+	benchSink = n
+}
+
+func TestLengthFloatError(t *testing.T) {
+	tests := []interface{}{
+		math.NaN(),
+		math.Inf(1),
+		float32(math.Inf(-1)),
+		[]float64{1, math.NaN()},
+		map[string]float64{"a": math.Inf(1)},
+		struct{ F float32 }{float32(math.NaN())},
+	}
+
+	for _, test := range tests {
+		if _, err := Length(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+func TestLengthNumber(t *testing.T) {
+	tests := []struct {
+		v     json.Number
+		n     int
+		valid bool
+	}{
+		{"", 1, true},
+		{"0", 1, true},
+		{"-1.5e+10", 8, true},
+		{"123456789012345678901234567890", 30, true},
+		{"01", 2, false},
+		{"1.", 2, false},
+		{"1e", 2, false},
+		{"-", 1, false},
+		{"abc", 3, false},
+		{"1 ", 2, false},
+	}
+
+	for _, test := range tests {
+		if n, err := Length(test.v); err != nil || n != test.n {
+			t.Errorf("%q: %d != %d (%v)", test.v, test.n, n, err)
+		}
+
+		n, err := LengthWithOptions(test.v, StdLengthOptions)
+		_, stdErr := json.Marshal(test.v)
+
+		switch {
+		case (err == nil) != test.valid || (stdErr == nil) != test.valid:
+			t.Errorf("%q: invalid validation: %v (standard json package: %v)", test.v, err, stdErr)
+		case test.valid && n != test.n:
+			t.Errorf("%q: %d != %d", test.v, test.n, n)
+		}
+	}
+}
+
+func TestLengthFloatAllocs(t *testing.T) {
+	if n := testing.AllocsPerRun(100, func() { jsonLenFloat(1.0000000000000002e-7, 64, &LegacyLengthOptions) }); n != 0 {
+		t.Error("too many memory allocations:", n)
+	}
+}
+
+func FuzzLengthFloat64(f *testing.F) {
+	for _, v := range []float64{0, 1, -0.5, 1e20, 1e21, 1e-6, 1e-7, math.MaxFloat64, math.SmallestNonzeroFloat64} {
+		f.Add(math.Float64bits(v))
+	}
+
+	f.Fuzz(func(t *testing.T, bits uint64) {
+		v := math.Float64frombits(bits)
+		b, err1 := json.Marshal(v)
+		n, err2 := Length(v)
+
+		if (err1 != nil) != (err2 != nil) {
+			t.Fatalf("%v: error mismatch: %v != %v", v, err1, err2)
+		}
+		if err1 == nil && n != len(b) {
+			t.Fatalf("%v: %d != %d (%s)", v, len(b), n, b)
+		}
+	})
+}
+
+func FuzzLengthFloat32(f *testing.F) {
+	for _, v := range []float32{0, 1, -0.5, 1e20, 1e21, 1e-6, 1e-7, math.MaxFloat32, math.SmallestNonzeroFloat32} {
+		f.Add(math.Float32bits(v))
+	}
+
+	f.Fuzz(func(t *testing.T, bits uint32) {
+		v := math.Float32frombits(bits)
+		b, err1 := json.Marshal(v)
+		n, err2 := Length(v)
+
+		if (err1 != nil) != (err2 != nil) {
+			t.Fatalf("%v: error mismatch: %v != %v", v, err1, err2)
+		}
+		if err1 == nil && n != len(b) {
+			t.Fatalf("%v: %d != %d (%s)", v, len(b), n, b)
+		}
+	})
+}
@@ -0,0 +1,356 @@
+package jutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// OrderedMap is a map of string keys to arbitrary values which remembers the
+// order in which the keys were inserted, and preserves it when it's serialized
+// to JSON or decoded from JSON.
+//
+// The zero-value is an empty map ready to use. OrderedMap values are not safe
+// to use concurrently from multiple goroutines.
+type OrderedMap struct {
+	// When OrderNested is true, UnmarshalJSON decodes nested objects as
+	// *OrderedMap values (with OrderNested also set) instead of the
+	// map[string]interface{} values produced by the standard json package.
+	OrderNested bool
+
+	entries []orderedMapEntry
+	index   map[string]int
+}
+
+type orderedMapEntry struct {
+	key   string
+	value interface{}
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.entries)
+}
+
+// Get returns the value associated with key, and a boolean indicating whether
+// the key existed in the map.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	if i, ok := m.index[key]; ok {
+		return m.entries[i].value, true
+	}
+	return nil, false
+}
+
+// Set associates value with key. If the key already existed its value is
+// replaced and it keeps its position, otherwise it's added after the other
+// keys.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if i, ok := m.index[key]; ok {
+		m.entries[i].value = value
+		return
+	}
+
+	if m.index == nil {
+		m.index = make(map[string]int)
+	}
+
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, orderedMapEntry{key: key, value: value})
+}
+
+// Delete removes key from the map, it's a no-op if the key didn't exist.
+func (m *OrderedMap) Delete(key string) {
+	i, ok := m.index[key]
+	if !ok {
+		return
+	}
+
+	delete(m.index, key)
+	copy(m.entries[i:], m.entries[i+1:])
+	m.entries[len(m.entries)-1] = orderedMapEntry{}
+	m.entries = m.entries[:len(m.entries)-1]
+
+	for j := i; j != len(m.entries); j++ {
+		m.index[m.entries[j].key] = j
+	}
+}
+
+// Keys returns the list of keys in the map, in insertion order.
+func (m *OrderedMap) Keys() []string {
+	keys := make([]string, len(m.entries))
+	for i, e := range m.entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// Range calls f for each key and value of the map in insertion order, stopping
+// if f returns false. The map must not be modified by f.
+func (m *OrderedMap) Range(f func(key string, value interface{}) bool) {
+	for _, e := range m.entries {
+		if !f(e.key, e.value) {
+			break
+		}
+	}
+}
+
+// LengthJSON satisfies the Lengther interface, it returns the length computed
+// by LengthJSONWithOptions with the options used by Length.
+//
+// The interface has no way to report errors, so the length is zero if one of
+// the values can't be serialized. Length and LengthWithOptions use
+// LengthJSONWithOptions instead and return the error.
+func (m *OrderedMap) LengthJSON() int {
+	n, err := m.LengthJSONWithOptions(&LegacyLengthOptions)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// LengthJSONWithOptions satisfies the OptionsLengther interface, an error is
+// returned if the length of one of the values can't be computed.
+func (m *OrderedMap) LengthJSONWithOptions(o *LengthOptions) (n int, err error) {
+	if m == nil {
+		return jsonLenNull(), nil
+	}
+	for i, e := range m.entries {
+		var c int
+		if c, err = jsonLen(e.value, o); err != nil {
+			return
+		}
+		if i != 0 {
+			n++
+		}
+		n += o.lenString(e.key) + c + 1
+	}
+	return n + 2, nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, the keys are written in
+// insertion order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	return Marshal(m)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, it replaces the
+// content of the map with the members of the object in b, in the order they
+// appear. If a key is repeated the last value is retained at the position of
+// the first occurrence.
+func (m *OrderedMap) UnmarshalJSON(b []byte) error {
+	d := orderedDecoder{nested: m.OrderNested}
+	d.tok.Reset(b)
+
+	if err := d.next(); err != nil {
+		return err
+	}
+
+	switch d.tok.Kind {
+	case Null:
+		return d.end()
+	case ObjectStart:
+	default:
+		return &json.UnmarshalTypeError{
+			Value:  jsonKind(d.tok.Kind),
+			Type:   reflect.TypeOf(m),
+			Offset: int64(d.tok.Offset),
+		}
+	}
+
+	m.reset()
+
+	if err := d.object(m); err != nil {
+		return err
+	}
+
+	return d.end()
+}
+
+func (m *OrderedMap) reset() {
+	for i := range m.entries {
+		m.entries[i] = orderedMapEntry{}
+	}
+	m.entries = m.entries[:0]
+	m.index = nil
+}
+
+func (e *Encoder) appendOrderedMap(dst []byte, m *OrderedMap) ([]byte, error) {
+	var err error
+
+	if m == nil {
+		return append(dst, "null"...), nil
+	}
+
+	dst = append(dst, '{')
+
+	for i, entry := range m.entries {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, entry.key, e.EscapeHTML)
+		dst = append(dst, ':')
+		if dst, err = e.Append(dst, entry.value); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+// orderedDecoder decodes JSON content into the values produced by the standard
+// json package when decoding to an empty interface, optionally using ordered
+// maps for nested objects.
+type orderedDecoder struct {
+	tok    Tokenizer
+	nested bool
+}
+
+func (d *orderedDecoder) next() error {
+	if !d.tok.Next() {
+		if d.tok.Err != nil {
+			return d.tok.Err
+		}
+		return syntaxErrorEOF(d.tok.input, d.tok.Pos())
+	}
+	return nil
+}
+
+func (d *orderedDecoder) end() error {
+	if d.tok.Next() {
+		return syntaxErrorChar(d.tok.input, d.tok.Offset, ctxEnd)
+	}
+	return d.tok.Err
+}
+
+func (d *orderedDecoder) value() (interface{}, error) {
+	if err := d.next(); err != nil {
+		return nil, err
+	}
+	return d.current()
+}
+
+// current decodes the value starting at the current token.
+func (d *orderedDecoder) current() (interface{}, error) {
+	t := &d.tok
+
+	switch t.Kind {
+	case ObjectStart:
+		if d.nested {
+			m := &OrderedMap{OrderNested: true}
+			return m, d.object(m)
+		}
+		return d.goMap()
+
+	case ArrayStart:
+		return d.array()
+
+	case String:
+		s, err := Unquote(t.Value)
+		return string(s), err
+
+	case Number:
+		f, err := strconv.ParseFloat(string(t.Value), 64)
+		if err != nil {
+			return nil, &json.UnmarshalTypeError{
+				Value:  "number " + string(t.Value),
+				Type:   reflect.TypeOf(f),
+				Offset: int64(t.Offset),
+			}
+		}
+		return f, nil
+
+	case True:
+		return true, nil
+
+	case False:
+		return false, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func (d *orderedDecoder) array() ([]interface{}, error) {
+	t := &d.tok
+	a := []interface{}{}
+
+	for {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+
+		switch t.Kind {
+		case ArrayEnd:
+			return a, nil
+		case Comma:
+			continue
+		}
+
+		v, err := d.current()
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, v)
+	}
+}
+
+func (d *orderedDecoder) object(m *OrderedMap) error {
+	return d.members(func(k string, v interface{}) { m.Set(k, v) })
+}
+
+func (d *orderedDecoder) goMap() (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	return m, d.members(func(k string, v interface{}) { m[k] = v })
+}
+
+func (d *orderedDecoder) members(set func(string, interface{})) error {
+	t := &d.tok
+
+	for {
+		if err := d.next(); err != nil {
+			return err
+		}
+
+		switch t.Kind {
+		case ObjectEnd:
+			return nil
+		case Comma:
+			continue
+		}
+
+		k, err := Unquote(t.Value)
+		if err != nil {
+			return err
+		}
+
+		if err := d.next(); err != nil { // colon
+			return err
+		}
+
+		v, err := d.value()
+		if err != nil {
+			return err
+		}
+
+		set(string(k), v)
+	}
+}
+
+// jsonKind returns the name of the kind of JSON values starting with a token
+// of kind k, as used in the errors of the standard json package.
+func jsonKind(k TokenKind) string {
+	switch k {
+	case ObjectStart:
+		return "object"
+	case ArrayStart:
+		return "array"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case True, False:
+		return "bool"
+	}
+	return "null"
+}
@@ -0,0 +1,194 @@
+package jutil
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := &OrderedMap{}
+
+	m.Set("c", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Set("a", 4)
+
+	if n := m.Len(); n != 3 {
+		t.Error("invalid length:", n)
+	}
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"c", "a", "b"}) {
+		t.Error("invalid keys:", keys)
+	}
+
+	if v, ok := m.Get("a"); !ok || v != 4 {
+		t.Error("invalid value:", v, ok)
+	}
+
+	if v, ok := m.Get("z"); ok || v != nil {
+		t.Error("invalid value:", v, ok)
+	}
+
+	m.Delete("c")
+	m.Delete("z")
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Error("invalid keys after delete:", keys)
+	}
+
+	if v, ok := m.Get("b"); !ok || v != 3 {
+		t.Error("invalid value after delete:", v, ok)
+	}
+
+	m.Set("c", 5)
+
+	var keys []string
+	m.Range(func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return k != "b"
+	})
+
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Error("invalid range:", keys)
+	}
+}
+
+func TestOrderedMapMarshal(t *testing.T) {
+	m := &OrderedMap{}
+	m.Set("z", []int{1, 2})
+	m.Set("<", map[string]int{"b": 1, "a": 2})
+	m.Set("a", nil)
+
+	const expect = `{"z":[1,2],"\u003c":{"a":2,"b":1},"a":null}`
+
+	for _, marshal := range []func(interface{}) ([]byte, error){json.Marshal, Marshal} {
+		if b, err := marshal(m); err != nil {
+			t.Error(err)
+		} else if s := string(b); s != expect {
+			t.Error("invalid output:", s)
+		}
+	}
+
+	if n, err := Length(m); err != nil {
+		t.Error(err)
+	} else if n != len(expect)-5 { // Length doesn't escape HTML characters
+		t.Error("invalid length:", n)
+	}
+
+	if n, err := LengthWithOptions(m, StdLengthOptions); err != nil {
+		t.Error(err)
+	} else if n != len(expect) {
+		t.Error("invalid length with the standard options:", n)
+	}
+
+	if b, err := json.Marshal((*OrderedMap)(nil)); err != nil {
+		t.Error(err)
+	} else if s := string(b); s != "null" {
+		t.Error("invalid output:", s)
+	}
+}
+
+func TestOrderedMapLengthWithOptions(t *testing.T) {
+	m := &OrderedMap{}
+	m.Set("<a>", "x&y")
+	m.Set("b", []interface{}{"\u2028", nil})
+
+	expect, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := LengthWithOptions(m, StdLengthOptions); err != nil {
+		t.Error(err)
+	} else if n != len(expect) {
+		t.Errorf("invalid length: %d != %d (%s)", n, len(expect), expect)
+	}
+
+	if n, err := Length(m); err != nil {
+		t.Error(err)
+	} else if n != m.LengthJSON() {
+		t.Errorf("invalid length: %d != %d", n, m.LengthJSON())
+	}
+
+	m.Set("c", math.NaN())
+
+	if _, err := LengthWithOptions(m, StdLengthOptions); err == nil {
+		t.Error("expected an error for a value that can't be serialized")
+	}
+
+	if _, err := Length(m); err == nil {
+		t.Error("expected an error for a value that can't be serialized")
+	}
+
+	if n := m.LengthJSON(); n != 0 {
+		t.Errorf("invalid length of a map that can't be serialized: %d", n)
+	}
+}
+
+func TestOrderedMapUnmarshal(t *testing.T) {
+	const input = `{"z":1,"a":{"y":true,"b":[{"x":"1"}]},"m":null,"z":2}`
+
+	m := &OrderedMap{}
+
+	if err := json.Unmarshal([]byte(input), m); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"z", "a", "m"}) {
+		t.Error("invalid keys:", keys)
+	}
+
+	if v, _ := m.Get("z"); v != 2.0 {
+		t.Error("invalid value:", v)
+	}
+
+	if v, _ := m.Get("a"); !reflect.DeepEqual(v, map[string]interface{}{"y": true, "b": []interface{}{map[string]interface{}{"x": "1"}}}) {
+		t.Errorf("invalid nested value: %#v", v)
+	}
+
+	n := &OrderedMap{OrderNested: true}
+
+	if err := json.Unmarshal([]byte(input), n); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := n.Get("a")
+	a, ok := v.(*OrderedMap)
+	if !ok {
+		t.Fatalf("invalid nested value: %#v", v)
+	}
+
+	if keys := a.Keys(); !reflect.DeepEqual(keys, []string{"y", "b"}) {
+		t.Error("invalid nested keys:", keys)
+	}
+
+	if b, _ := a.Get("b"); reflect.TypeOf(b.([]interface{})[0]) != reflect.TypeOf(a) {
+		t.Errorf("invalid nested value: %#v", b)
+	}
+
+	if b, err := json.Marshal(n); err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `{"z":2,"a":{"y":true,"b":[{"x":"1"}]},"m":null}` {
+		t.Error("invalid output:", s)
+	}
+}
+
+func TestOrderedMapUnmarshalError(t *testing.T) {
+	tests := []string{
+		``,
+		`[]`,
+		`"a"`,
+		`{"a":}`,
+		`{"a":1}{}`,
+		`{"a":1e1000}`,
+	}
+
+	for _, test := range tests {
+		m := &OrderedMap{}
+		if err := m.UnmarshalJSON([]byte(test)); err == nil {
+			t.Errorf("%q: expected an error", test)
+		}
+	}
+}
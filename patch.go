@@ -0,0 +1,671 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Operation represents a single operation of a JSON Patch document as defined
+// in RFC 6902.
+type Operation struct {
+	// The operation to perform, one of "add", "remove", "replace", "move",
+	// "copy" or "test".
+	Op string
+
+	// The JSON Pointer referencing the target location of the operation.
+	Path string
+
+	// The JSON Pointer referencing the source location of "move" and "copy"
+	// operations.
+	From string
+
+	// The value used by "add", "replace" and "test" operations.
+	Value interface{}
+}
+
+// Patch represents a JSON Patch document as defined in RFC 6902.
+type Patch []Operation
+
+// ParsePatch decodes a JSON Patch document.
+//
+// Numbers in the operation values are decoded as json.Number so they are not
+// altered when applying the patch.
+func ParsePatch(b []byte) (Patch, error) {
+	var ops []struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal(b, &ops); err != nil {
+		return nil, err
+	}
+
+	patch := make(Patch, len(ops))
+
+	for i, op := range ops {
+		patch[i].Op = op.Op
+
+		if op.Path == nil {
+			return nil, &PatchError{Index: i, Op: op.Op, Err: errors.New(`missing "path" member`)}
+		}
+		patch[i].Path = *op.Path
+
+		switch op.Op {
+		case "move", "copy":
+			if op.From == nil {
+				return nil, &PatchError{Index: i, Op: op.Op, Path: *op.Path, Err: errors.New(`missing "from" member`)}
+			}
+			patch[i].From = *op.From
+
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, &PatchError{Index: i, Op: op.Op, Path: *op.Path, Err: errors.New(`missing "value" member`)}
+			}
+			v, err := decodeTree(op.Value)
+			if err != nil {
+				return nil, err
+			}
+			patch[i].Value = v
+
+		case "remove":
+		default:
+			return nil, &PatchError{Index: i, Op: op.Op, Path: *op.Path, Err: errors.New("unknown operation")}
+		}
+	}
+
+	return patch, nil
+}
+
+// ApplyPatch applies the JSON Patch document patch to the JSON document doc and
+// returns the resulting document.
+//
+// The patch is applied atomically, if any of the operations fails an error is
+// returned and no document is produced.
+func ApplyPatch(doc []byte, patch []byte) ([]byte, error) {
+	p, err := ParsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := decodeTree(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, err = p.apply(v); err != nil {
+		return nil, err
+	}
+
+	return encodeTree(v)
+}
+
+// Apply applies the patch to doc, which is a tree of values like those
+// produced by decoding JSON into an interface{} value, and returns the
+// resulting tree.
+//
+// The patch is applied atomically on a copy of doc, which is never modified.
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	return p.apply(copyTree(doc))
+}
+
+func (p Patch) apply(doc interface{}) (interface{}, error) {
+	for i, op := range p {
+		var err error
+
+		if doc, err = op.apply(doc); err != nil {
+			return nil, &PatchError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+func (op Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return patchAdd(doc, path, copyTree(op.Value))
+
+	case "remove":
+		return patchRemove(doc, path)
+
+	case "replace":
+		if len(path) == 0 {
+			return copyTree(op.Value), nil
+		}
+		return patchEdit(doc, path, 0, func(parent interface{}, i int) (interface{}, error) {
+			return patchReplace(parent, path, i, copyTree(op.Value))
+		})
+
+	case "move":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		v, err := from.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = patchRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, v)
+
+	case "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := from.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, copyTree(v))
+
+	case "test":
+		v, err := path.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !treeEqual(v, op.Value) {
+			return nil, errPatchTestFailed
+		}
+		return doc, nil
+	}
+
+	return nil, errors.New("unknown operation")
+}
+
+// patchEdit walks doc following path and calls edit with the container holding
+// the value referenced by path, returning the modified document.
+func patchEdit(doc interface{}, path Pointer, i int, edit func(interface{}, int) (interface{}, error)) (interface{}, error) {
+	if i == len(path)-1 {
+		return edit(doc, i)
+	}
+
+	t := path[i]
+
+	switch x := doc.(type) {
+	case map[string]interface{}:
+		child, ok := x[t]
+		if !ok {
+			return nil, path.error(i, ErrPointerNotFound)
+		}
+		child, err := patchEdit(child, path, i+1, edit)
+		if err != nil {
+			return nil, err
+		}
+		x[t] = child
+
+	case []interface{}:
+		j, err := parsePointerIndex(t, len(x))
+		if err != nil {
+			return nil, path.error(i, err)
+		}
+		if x[j], err = patchEdit(x[j], path, i+1, edit); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, path.error(i, errPatchScalar)
+	}
+
+	return doc, nil
+}
+
+func patchAdd(doc interface{}, path Pointer, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+
+	return patchEdit(doc, path, 0, func(parent interface{}, i int) (interface{}, error) {
+		switch x := parent.(type) {
+		case map[string]interface{}:
+			x[path[i]] = v
+			return x, nil
+
+		case []interface{}:
+			if path[i] == "-" {
+				return append(x, v), nil
+			}
+			j, err := parsePointerIndex(path[i], len(x)+1)
+			if err != nil {
+				return nil, path.error(i, err)
+			}
+			x = append(x, nil)
+			copy(x[j+1:], x[j:])
+			x[j] = v
+			return x, nil
+		}
+
+		return nil, path.error(i, errPatchScalar)
+	})
+}
+
+func patchRemove(doc interface{}, path Pointer) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+
+	return patchEdit(doc, path, 0, func(parent interface{}, i int) (interface{}, error) {
+		switch x := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := x[path[i]]; !ok {
+				return nil, path.error(i, ErrPointerNotFound)
+			}
+			delete(x, path[i])
+			return x, nil
+
+		case []interface{}:
+			j, err := parsePointerIndex(path[i], len(x))
+			if err != nil {
+				return nil, path.error(i, err)
+			}
+			return append(x[:j], x[j+1:]...), nil
+		}
+
+		return nil, path.error(i, errPatchScalar)
+	})
+}
+
+func patchReplace(parent interface{}, path Pointer, i int, v interface{}) (interface{}, error) {
+	switch x := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := x[path[i]]; !ok {
+			return nil, path.error(i, ErrPointerNotFound)
+		}
+		x[path[i]] = v
+		return x, nil
+
+	case []interface{}:
+		j, err := parsePointerIndex(path[i], len(x))
+		if err != nil {
+			return nil, path.error(i, err)
+		}
+		x[j] = v
+		return x, nil
+	}
+
+	return nil, path.error(i, errPatchScalar)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (p Patch) MarshalJSON() ([]byte, error) {
+	w := &bytes.Buffer{}
+	w.WriteByte('[')
+
+	for i, op := range p {
+		if i != 0 {
+			w.WriteByte(',')
+		}
+
+		w.WriteString(`{"op":`)
+		writeString(w, op.Op)
+		w.WriteString(`,"path":`)
+		writeString(w, op.Path)
+
+		switch op.Op {
+		case "move", "copy":
+			w.WriteString(`,"from":`)
+			writeString(w, op.From)
+
+		case "add", "replace", "test":
+			w.WriteString(`,"value":`)
+			if err := writeTree(w, op.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(']')
+	return w.Bytes(), nil
+}
+
+// PatchError is returned when applying a JSON Patch fails.
+type PatchError struct {
+	// The index of the operation that failed in the patch.
+	Index int
+
+	// The name of the operation that failed.
+	Op string
+
+	// The path of the operation that failed.
+	Path string
+
+	// The reason why the operation failed, when the error is related to one
+	// of the reference tokens of the path it is a *PointerError.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("jutil: patch operation #%d (%s %q): %s", e.Index, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// MergePatch applies the JSON Merge Patch patch to the JSON document doc, as
+// defined in RFC 7396, and returns the resulting document.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	d, err := decodeTree(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodeTree(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeTree(mergeTree(d, p))
+}
+
+// MergeValue applies a JSON Merge Patch to doc, both arguments being trees of
+// values like those produced by decoding JSON into an interface{} value, and
+// returns the resulting tree.
+//
+// doc is never modified, the parts of the returned tree that are not affected
+// by the patch may be shared with it.
+func MergeValue(doc interface{}, patch interface{}) interface{} {
+	return mergeTree(copyTree(doc), patch)
+}
+
+func mergeTree(doc interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return copyTree(patch)
+	}
+
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{}, len(p))
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+		} else {
+			d[k] = mergeTree(d[k], v)
+		}
+	}
+
+	return d
+}
+
+// CreatePatch computes the JSON Patch document that transforms the JSON
+// document a into the JSON document b.
+func CreatePatch(a []byte, b []byte) ([]byte, error) {
+	va, err := decodeTree(a)
+	if err != nil {
+		return nil, err
+	}
+
+	vb, err := decodeTree(b)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Diff(va, vb)
+	if p == nil {
+		p = Patch{}
+	}
+	return p.MarshalJSON()
+}
+
+// Diff computes the JSON Patch that transforms a into b, both arguments being
+// trees of values like those produced by decoding JSON into an interface{}
+// value.
+//
+// Object members are compared recursively, arrays are compared element by
+// element and extended or truncated at the end, any other difference results
+// in a "replace" operation.
+func Diff(a interface{}, b interface{}) Patch {
+	return diffTree(nil, Pointer{}, a, b)
+}
+
+func diffTree(p Patch, path Pointer, a interface{}, b interface{}) Patch {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		if y, ok := b.(map[string]interface{}); ok {
+			return diffMap(p, path, x, y)
+		}
+
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			return diffSlice(p, path, x, y)
+		}
+	}
+
+	if !treeEqual(a, b) {
+		p = append(p, Operation{Op: "replace", Path: path.String(), Value: copyTree(b)})
+	}
+
+	return p
+}
+
+func diffMap(p Patch, path Pointer, a map[string]interface{}, b map[string]interface{}) Patch {
+	for _, k := range sortedKeys(a) {
+		if _, ok := b[k]; !ok {
+			p = append(p, Operation{Op: "remove", Path: path.child(k).String()})
+		}
+	}
+
+	for _, k := range sortedKeys(b) {
+		if v, ok := a[k]; ok {
+			p = diffTree(p, path.child(k), v, b[k])
+		} else {
+			p = append(p, Operation{Op: "add", Path: path.child(k).String(), Value: copyTree(b[k])})
+		}
+	}
+
+	return p
+}
+
+func diffSlice(p Patch, path Pointer, a []interface{}, b []interface{}) Patch {
+	n := len(a)
+	if n > len(b) {
+		n = len(b)
+	}
+
+	for i := 0; i != n; i++ {
+		p = diffTree(p, path.child(strconv.Itoa(i)), a[i], b[i])
+	}
+
+	for i := len(a) - 1; i >= n; i-- {
+		p = append(p, Operation{Op: "remove", Path: path.child(strconv.Itoa(i)).String()})
+	}
+
+	for i := n; i < len(b); i++ {
+		p = append(p, Operation{Op: "add", Path: path.child(strconv.Itoa(i)).String(), Value: copyTree(b[i])})
+	}
+
+	return p
+}
+
+func (p Pointer) child(t string) Pointer {
+	c := make(Pointer, len(p)+1)
+	copy(c, p)
+	c[len(p)] = t
+	return c
+}
+
+// The functions below operate on trees of values like those produced by
+// decoding JSON into an interface{} value.
+
+// decodeTree decodes the JSON document b into a tree of values. The document is
+// validated first so syntax errors, including data after the top-level value,
+// are reported at the offset of the first invalid byte.
+func decodeTree(b []byte) (v interface{}, err error) {
+	if err = Validate(b); err != nil {
+		return
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&v)
+	return
+}
+
+func encodeTree(v interface{}) ([]byte, error) {
+	w := &bytes.Buffer{}
+	if err := writeTree(w, v); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func writeTree(w *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		w.WriteString("null")
+
+	case bool:
+		w.WriteString(strconv.FormatBool(x))
+
+	case json.Number:
+		w.WriteString(string(x))
+
+	case string:
+		writeString(w, x)
+
+	case []interface{}:
+		w.WriteByte('[')
+		for i, e := range x {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			if err := writeTree(w, e); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+
+	case map[string]interface{}:
+		w.WriteByte('{')
+		for i, k := range sortedKeys(x) {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			writeString(w, k)
+			w.WriteByte(':')
+			if err := writeTree(w, x[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+	}
+
+	return nil
+}
+
+func copyTree(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		c := make([]interface{}, len(x))
+		for i, e := range x {
+			c[i] = copyTree(e)
+		}
+		return c
+
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			c[k] = copyTree(e)
+		}
+		return c
+	}
+
+	return v
+}
+
+func treeEqual(a interface{}, b interface{}) bool {
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !treeEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !treeEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if x, ok := treeNumber(a); ok {
+		y, ok := treeNumber(b)
+		return ok && x == y
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func treeNumber(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	switch x := reflect.ValueOf(v); x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(x.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(x.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return x.Float(), true
+	}
+
+	return 0, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	errPatchScalar     = errors.New("cannot reference into a scalar value")
+	errPatchTestFailed = errors.New("test failed, the values are not equal")
+)
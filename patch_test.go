@@ -0,0 +1,312 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	// Examples from appendix A of RFC 6902.
+	tests := []struct {
+		doc   string
+		patch string
+		res   string
+	}{
+		{
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			res:   `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			res:   `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			res:   `{"foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			res:   `{"foo":["bar","baz"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			res:   `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			res:   `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			res:   `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			res:   `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			res:   `{"child":{"grandchild":{}},"foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			res:   `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			doc:   `{"foo":null}`,
+			patch: `[{"op":"test","path":"/foo","value":null},{"op":"copy","from":"/foo","path":"/bar"}]`,
+			res:   `{"bar":null,"foo":null}`,
+		},
+		{
+			doc:   `{"/":1.000000000000000000001}`,
+			patch: `[{"op":"copy","from":"/~1","path":""}]`,
+			res:   `1.000000000000000000001`,
+		},
+	}
+
+	for _, test := range tests {
+		if res, err := ApplyPatch([]byte(test.doc), []byte(test.patch)); err != nil {
+			t.Errorf("%s: %s", test.patch, err)
+		} else if string(res) != test.res {
+			t.Errorf("%s: invalid result: %s != %s", test.patch, test.res, string(res))
+		}
+	}
+}
+
+func TestApplyPatchError(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		index int
+		token string
+	}{
+		{
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			token: "baz",
+		},
+		{
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"remove","path":"/bar"}]`,
+			index: 1,
+			token: "bar",
+		},
+		{
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"replace","path":"/foo/1","value":"qux"}]`,
+			token: "1",
+		},
+		{
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`,
+		},
+		{
+			doc:   `{"foo":{"bar":1}}`,
+			patch: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ApplyPatch([]byte(test.doc), []byte(test.patch))
+
+		var e *PatchError
+		var p *PointerError
+
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected a patch error but got %v", test.patch, err)
+		} else if e.Index != test.index {
+			t.Errorf("%s: invalid operation index: %d != %d", test.patch, test.index, e.Index)
+		} else if len(test.token) != 0 && (!errors.As(err, &p) || p.Token() != test.token) {
+			t.Errorf("%s: invalid pointer error: %v", test.patch, err)
+		}
+	}
+}
+
+func TestPatchSyntaxError(t *testing.T) {
+	tests := []struct {
+		doc    string
+		patch  string
+		merge  bool
+		offset int
+	}{
+		{doc: `{"a":1}]`, patch: `[]`, offset: 7},
+		{doc: `{"a":1} }`, patch: `[]`, offset: 8},
+		{doc: `{"a":1}`, patch: `[{"op":"add","path":"/b","value":1}}`, offset: -1},
+		{doc: `{"a":1}}}`, patch: `{}`, merge: true, offset: 7},
+		{doc: `{"a":1}`, patch: `{"b":2}]`, merge: true, offset: 7},
+		{doc: `{"a":1,}`, patch: `{}`, merge: true, offset: 7},
+		{doc: `[1] x`, patch: `{}`, merge: true, offset: 4},
+	}
+
+	for _, test := range tests {
+		var err error
+
+		if test.merge {
+			_, err = MergePatch([]byte(test.doc), []byte(test.patch))
+		} else {
+			_, err = ApplyPatch([]byte(test.doc), []byte(test.patch))
+		}
+
+		var e *SyntaxError
+
+		switch {
+		case err == nil:
+			t.Errorf("%s %s: expected an error", test.doc, test.patch)
+		case test.offset < 0:
+			// The patch is decoded by the standard json package.
+		case !errors.As(err, &e):
+			t.Errorf("%s %s: expected a syntax error but got %v", test.doc, test.patch, err)
+		case e.Offset != test.offset:
+			t.Errorf("%s %s: invalid error offset: %d != %d", test.doc, test.patch, test.offset, e.Offset)
+		}
+	}
+}
+
+func TestPatchApplyTree(t *testing.T) {
+	doc := map[string]interface{}{"a": []interface{}{1, 2}}
+
+	res, err := Patch{
+		{Op: "add", Path: "/a/0", Value: 0},
+		{Op: "remove", Path: "/a/2"},
+		{Op: "test", Path: "/a", Value: []interface{}{0, 1}},
+	}.Apply(doc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res, map[string]interface{}{"a": []interface{}{0, 1}}) {
+		t.Errorf("invalid result: %#v", res)
+	}
+
+	if !reflect.DeepEqual(doc, map[string]interface{}{"a": []interface{}{1, 2}}) {
+		t.Errorf("the document was modified: %#v", doc)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from appendix A of RFC 7396.
+	tests := []struct {
+		doc   string
+		patch string
+		res   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		if res, err := MergePatch([]byte(test.doc), []byte(test.patch)); err != nil {
+			t.Errorf("%s: %s", test.patch, err)
+		} else if string(res) != test.res {
+			t.Errorf("%s: invalid result: %s != %s", test.patch, test.res, string(res))
+		}
+	}
+}
+
+func TestPatchControlCharacters(t *testing.T) {
+	for c := 0; c != 0x20; c++ {
+		s, _ := json.Marshal(fmt.Sprintf("x%cy\v", c))
+		doc := []byte(fmt.Sprintf(`{"a":%s,%s:[%s]}`, s, s, s))
+
+		merged, err := MergePatch(doc, []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := CreatePatch([]byte(`{}`), doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		applied, err := ApplyPatch([]byte(`{}`), created)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, b := range [][]byte{merged, created, applied} {
+			if !json.Valid(b) {
+				t.Errorf("%#x: invalid JSON output: %q", c, b)
+			}
+		}
+
+		if !bytes.Equal(merged, applied) {
+			t.Errorf("%#x: %q != %q", c, merged, applied)
+		}
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		a     string
+		b     string
+		patch string
+	}{
+		{
+			a:     `{"a":1}`,
+			b:     `{"a":1}`,
+			patch: `[]`,
+		},
+		{
+			a:     `{"a":1,"b":{"c":[1,2,3]},"d/e":true}`,
+			b:     `{"a":2,"b":{"c":[1,4]},"f":null}`,
+			patch: `[{"op":"remove","path":"/d~1e"},{"op":"replace","path":"/a","value":2},{"op":"replace","path":"/b/c/1","value":4},{"op":"remove","path":"/b/c/2"},{"op":"add","path":"/f","value":null}]`,
+		},
+		{
+			a:     `[1]`,
+			b:     `[1,{"x":"y"}]`,
+			patch: `[{"op":"add","path":"/1","value":{"x":"y"}}]`,
+		},
+		{
+			a:     `"a"`,
+			b:     `["a"]`,
+			patch: `[{"op":"replace","path":"","value":["a"]}]`,
+		},
+	}
+
+	for _, test := range tests {
+		patch, err := CreatePatch([]byte(test.a), []byte(test.b))
+		if err != nil {
+			t.Errorf("%s => %s: %s", test.a, test.b, err)
+			continue
+		}
+
+		if string(patch) != test.patch {
+			t.Errorf("%s => %s: invalid patch: %s != %s", test.a, test.b, test.patch, string(patch))
+		}
+
+		res, err := ApplyPatch([]byte(test.a), patch)
+		if err != nil {
+			t.Errorf("%s => %s: %s", test.a, test.b, err)
+		} else if string(res) != test.b {
+			t.Errorf("%s => %s: invalid result: %s", test.a, test.b, string(res))
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Pointer represents a JSON Pointer as defined in RFC 6901, each element of
+// the slice is an unescaped reference token.
+//
+// The empty pointer references the whole document.
+type Pointer []string
+
+// ParsePointer parses the string representation of a JSON Pointer, returning
+// an error if it is not properly formatted.
+func ParsePointer(s string) (Pointer, error) {
+	if len(s) == 0 {
+		return Pointer{}, nil
+	}
+
+	if s[0] != '/' {
+		return nil, fmt.Errorf("jutil: invalid json pointer %q: must be empty or start with '/'", s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+
+	for i, t := range tokens {
+		if strings.IndexByte(t, '~') < 0 {
+			continue
+		}
+		for j := 0; j != len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, fmt.Errorf("jutil: invalid json pointer %q: bad escape sequence in %q", s, t)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(t)
+	}
+
+	return Pointer(tokens), nil
+}
+
+// String returns the representation of the pointer as defined in RFC 6901,
+// escaping `~` and `/` in reference tokens as `~0` and `~1`.
+func (p Pointer) String() string {
+	n := 0
+	for _, t := range p {
+		n += 1 + len(t)
+	}
+
+	s := make([]byte, 0, n)
+	for _, t := range p {
+		s = append(s, '/')
+		s = append(s, escapePointerToken(t)...)
+	}
+
+	return string(s)
+}
+
+// Get resolves the pointer on a Go value and returns the value it references.
+//
+// Maps, slices, arrays and structs are walked through, struct fields are
+// matched using the names reported by LookupStruct.
+func (p Pointer) Get(v interface{}) (interface{}, error) {
+	for i, t := range p {
+		var err error
+
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[t]; !ok {
+				err = ErrPointerNotFound
+			}
+
+		case []interface{}:
+			var j int
+			if j, err = parsePointerIndex(t, len(x)); err == nil {
+				v = x[j]
+			}
+
+		default:
+			v, err = pointerGetValue(reflect.ValueOf(v), t)
+		}
+
+		if err != nil {
+			return nil, p.error(i, err)
+		}
+	}
+
+	return v, nil
+}
+
+func pointerGetValue(v reflect.Value, t string) (interface{}, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil, ErrPointerNotFound
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		k, err := pointerMapKey(v.Type().Key(), t)
+		if err != nil {
+			return nil, err
+		}
+		if v = v.MapIndex(k); !v.IsValid() {
+			return nil, ErrPointerNotFound
+		}
+
+	case reflect.Slice, reflect.Array:
+		i, err := parsePointerIndex(t, v.Len())
+		if err != nil {
+			return nil, err
+		}
+		v = v.Index(i)
+
+	case reflect.Struct:
+		f, ok := LookupStruct(v.Type()).lookup(t)
+		if !ok {
+			return nil, ErrPointerNotFound
+		}
+		v = v.FieldByIndex(f.Index)
+
+	default:
+		return nil, fmt.Errorf("cannot reference into a value of type %s", v.Type())
+	}
+
+	if !v.CanInterface() {
+		return nil, fmt.Errorf("reflect: cannot call Interface on %v", v)
+	}
+
+	return v.Interface(), nil
+}
+
+func pointerMapKey(t reflect.Type, s string) (reflect.Value, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(s).Convert(t), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, ErrPointerNotFound
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, ErrPointerNotFound
+		}
+		return reflect.ValueOf(u).Convert(t), nil
+	}
+
+	return reflect.Value{}, &json.UnsupportedTypeError{Type: t}
+}
+
+// GetRaw resolves the pointer on raw JSON content and returns the slice of b
+// holding the value it references.
+//
+// The function only scans the parts of the document that it has to go through
+// to reach the referenced value, it does not decode it nor validate what comes
+// after it.
+func (p Pointer) GetRaw(b []byte) ([]byte, error) {
+	i := skipSpaces(b, 0)
+
+	for k, t := range p {
+		var err error
+
+		if i >= len(b) {
+			return nil, p.error(k, syntaxErrorEOF(b, i))
+		}
+
+		switch b[i] {
+		case '{':
+			i, err = pointerGetRawMember(b, i, t)
+		case '[':
+			i, err = pointerGetRawElement(b, i, t)
+		default:
+			err = errors.New("cannot reference into a scalar value")
+		}
+
+		if err != nil {
+			return nil, p.error(k, err)
+		}
+	}
+
+	j, err := skipValue(b, i)
+	if err != nil {
+		if len(p) != 0 {
+			err = p.error(len(p)-1, err)
+		}
+		return nil, err
+	}
+
+	return b[i:j], nil
+}
+
+func pointerGetRawMember(b []byte, i int, t string) (int, error) {
+	var err error
+	var j int
+
+	if i = skipSpaces(b, i+1); i < len(b) && b[i] == '}' {
+		return i, ErrPointerNotFound
+	}
+
+	for {
+		if i >= len(b) || b[i] != '"' {
+			return i, syntaxErrorChar(b, i, ctxKey)
+		}
+		if j, err = scanString(b, i); err != nil {
+			return j, err
+		}
+		key := b[i+1 : j-1]
+
+		if i = skipSpaces(b, j); i >= len(b) || b[i] != ':' {
+			return i, syntaxErrorChar(b, i, ctxColon)
+		}
+		i = skipSpaces(b, i+1)
+
+		if rawKeyEqual(key, t) {
+			return i, nil
+		}
+
+		if i, err = skipValue(b, i); err != nil {
+			return i, err
+		}
+		if i = skipSpaces(b, i); i >= len(b) {
+			return i, syntaxErrorChar(b, i, ctxObjectNext)
+		}
+		switch b[i] {
+		case ',':
+			i = skipSpaces(b, i+1)
+		case '}':
+			return i, ErrPointerNotFound
+		default:
+			return i, syntaxErrorChar(b, i, ctxObjectNext)
+		}
+	}
+}
+
+func pointerGetRawElement(b []byte, i int, t string) (int, error) {
+	n, err := parsePointerIndex(t, -1)
+	if err != nil {
+		return i, err
+	}
+	return rawElement(b, i, n)
+}
+
+// rawElement returns the offset of the element at index n of the array
+// starting at b[i].
+func rawElement(b []byte, i int, n int) (int, error) {
+	var err error
+
+	if i = skipSpaces(b, i+1); i < len(b) && b[i] == ']' {
+		return i, ErrPointerNotFound
+	}
+
+	for ; n != 0; n-- {
+		if i, err = skipValue(b, i); err != nil {
+			return i, err
+		}
+		if i = skipSpaces(b, i); i >= len(b) {
+			return i, syntaxErrorChar(b, i, ctxArrayNext)
+		}
+		switch b[i] {
+		case ',':
+			i = skipSpaces(b, i+1)
+		case ']':
+			return i, ErrPointerNotFound
+		default:
+			return i, syntaxErrorChar(b, i, ctxArrayNext)
+		}
+	}
+
+	return i, nil
+}
+
+// rawKeyEqual compares the content of a raw JSON string with an unescaped
+// key, only decoding the raw string if it contains escape sequences.
+func rawKeyEqual(raw []byte, key string) bool {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw) == key
+	}
+	u, err := Unescape(raw)
+	return err == nil && string(u) == key
+}
+
+// parsePointerIndex parses a reference token as an array index, n is the
+// length of the array or -1 if it is unknown.
+func parsePointerIndex(t string, n int) (int, error) {
+	if t == "-" {
+		return 0, errors.New("the '-' token references a nonexistent element")
+	}
+
+	if len(t) == 0 || (len(t) > 1 && t[0] == '0') || skipDigits([]byte(t), 0) != len(t) {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+
+	i, err := strconv.Atoi(t)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+
+	if n >= 0 && i >= n {
+		return 0, ErrPointerNotFound
+	}
+
+	return i, nil
+}
+
+func (p Pointer) error(i int, err error) error {
+	return &PointerError{Pointer: p, Index: i, Err: err}
+}
+
+// PointerError is returned when resolving a JSON Pointer fails.
+type PointerError struct {
+	// The pointer that was being resolved.
+	Pointer Pointer
+
+	// The index of the reference token that couldn't be resolved.
+	Index int
+
+	// The reason why the reference token couldn't be resolved.
+	Err error
+}
+
+// Token returns the reference token that couldn't be resolved.
+func (e *PointerError) Token() string {
+	return e.Pointer[e.Index]
+}
+
+// Error satisfies the error interface.
+func (e *PointerError) Error() string {
+	return fmt.Sprintf("jutil: resolving %q: reference token %q: %s", e.Pointer.String(), e.Token(), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PointerError) Unwrap() error {
+	return e.Err
+}
+
+// ErrPointerNotFound is the error wrapped by PointerError when a reference
+// token doesn't match any value.
+var ErrPointerNotFound = errors.New("value not found")
+
+var (
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
@@ -0,0 +1,199 @@
+package jutil
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		s string
+		p Pointer
+	}{
+		{
+			s: ``,
+			p: Pointer{},
+		},
+		{
+			s: `/`,
+			p: Pointer{""},
+		},
+		{
+			s: `/properties/0/name`,
+			p: Pointer{"properties", "0", "name"},
+		},
+		{
+			s: `/a~1b/m~0n/~01`,
+			p: Pointer{"a/b", "m~n", "~1"},
+		},
+	}
+
+	for _, test := range tests {
+		p, err := ParsePointer(test.s)
+
+		if err != nil {
+			t.Errorf("%#v: %s", test.s, err)
+		} else if !reflect.DeepEqual(p, test.p) {
+			t.Errorf("%#v: invalid pointer: %#v != %#v", test.s, test.p, p)
+		} else if s := p.String(); s != test.s {
+			t.Errorf("%#v: invalid string: %#v", test.s, s)
+		}
+	}
+}
+
+func TestParsePointerError(t *testing.T) {
+	tests := []string{
+		`a`,
+		`/~`,
+		`/~2`,
+		`/a~/b`,
+	}
+
+	for _, test := range tests {
+		if _, err := ParsePointer(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+// The document from section 5 of RFC 6901.
+const pointerTestDocument = `{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8,
+	"é": 9,
+	"properties": [{"name": "A"}, {"name": "B", "tags": [true, null]}]
+}`
+
+func TestPointerGet(t *testing.T) {
+	type property struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags,omitempty"`
+	}
+
+	type document struct {
+		Properties []*property         `json:"properties"`
+		Counts     map[int]int         `json:"counts"`
+		Attrs      map[string][]string `json:"attrs"`
+	}
+
+	var tree interface{}
+	json.Unmarshal([]byte(pointerTestDocument), &tree)
+
+	doc := &document{
+		Properties: []*property{{Name: "A"}, {Name: "B", Tags: []string{"x"}}},
+		Counts:     map[int]int{42: 1},
+		Attrs:      map[string][]string{"a/b": {"c"}},
+	}
+
+	tests := []struct {
+		v interface{}
+		p string
+		r interface{}
+	}{
+		{tree, ``, tree},
+		{tree, `/foo`, []interface{}{"bar", "baz"}},
+		{tree, `/foo/0`, "bar"},
+		{tree, `/`, 0.0},
+		{tree, `/a~1b`, 1.0},
+		{tree, `/i\j`, 5.0},
+		{tree, `/k"l`, 6.0},
+		{tree, `/m~0n`, 8.0},
+		{tree, `/properties/1/name`, "B"},
+		{doc, `/properties/0/name`, "A"},
+		{doc, `/properties/1/tags/0`, "x"},
+		{doc, `/counts/42`, 1},
+		{doc, `/attrs/a~1b/0`, "c"},
+	}
+
+	for _, test := range tests {
+		p, _ := ParsePointer(test.p)
+
+		if r, err := p.Get(test.v); err != nil {
+			t.Errorf("%#v: %s", test.p, err)
+		} else if !reflect.DeepEqual(r, test.r) {
+			t.Errorf("%#v: invalid value: %#v != %#v", test.p, test.r, r)
+		}
+	}
+}
+
+func TestPointerGetRaw(t *testing.T) {
+	tests := []struct {
+		p string
+		r string
+	}{
+		{`/foo`, `["bar", "baz"]`},
+		{`/foo/0`, `"bar"`},
+		{`/foo/1`, `"baz"`},
+		{`/`, `0`},
+		{`/a~1b`, `1`},
+		{`/c%d`, `2`},
+		{`/e^f`, `3`},
+		{`/g|h`, `4`},
+		{`/i\j`, `5`},
+		{`/k"l`, `6`},
+		{`/ `, `7`},
+		{`/m~0n`, `8`},
+		{`/é`, `9`},
+		{`/properties/1`, `{"name": "B", "tags": [true, null]}`},
+		{`/properties/1/tags/1`, `null`},
+	}
+
+	for _, test := range tests {
+		p, _ := ParsePointer(test.p)
+
+		if r, err := p.GetRaw([]byte(pointerTestDocument)); err != nil {
+			t.Errorf("%#v: %s", test.p, err)
+		} else if string(r) != test.r {
+			t.Errorf("%#v: invalid value: %#v != %#v", test.p, test.r, string(r))
+		}
+	}
+}
+
+func TestPointerError(t *testing.T) {
+	var tree interface{}
+	json.Unmarshal([]byte(pointerTestDocument), &tree)
+
+	tests := []struct {
+		p     string
+		token string
+	}{
+		{`/nope`, "nope"},
+		{`/foo/2`, "2"},
+		{`/foo/-`, "-"},
+		{`/foo/01`, "01"},
+		{`/foo/0/bar`, "bar"},
+		{`/properties/1/tags/x`, "x"},
+	}
+
+	for _, test := range tests {
+		p, _ := ParsePointer(test.p)
+
+		_, err1 := p.Get(tree)
+		_, err2 := p.GetRaw([]byte(pointerTestDocument))
+
+		for _, err := range []error{err1, err2} {
+			var e *PointerError
+
+			if !errors.As(err, &e) {
+				t.Errorf("%#v: expected a pointer error but got %v", test.p, err)
+			} else if e.Token() != test.token {
+				t.Errorf("%#v: invalid reference token in error: %#v != %#v", test.p, test.token, e.Token())
+			}
+		}
+	}
+
+	p, _ := ParsePointer(`/foo/5`)
+	if _, err := p.GetRaw([]byte(pointerTestDocument)); !errors.Is(err, ErrPointerNotFound) {
+		t.Errorf("expected a not found error but got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package jutil
 
 import (
 	"bytes"
+	"errors"
 	"io"
 )
 
@@ -42,6 +43,25 @@ func WriteQuoted(w io.Writer, b []byte) (n int, err error) {
 	return
 }
 
+// UnquoteString takes a quoted JSON string as argument and returns its
+// unquoted and unescaped content.
+func UnquoteString(s string) (string, error) {
+	b, err := Unquote([]byte(s))
+	return string(b), err
+}
+
+// Unquote takes a byte slice holding a quoted JSON string as argument and
+// returns the copy of its content where every escape sequence has been
+// decoded.
+func Unquote(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+		return nil, errInvalidQuote
+	}
+	return Unescape(b[1 : len(b)-1])
+}
+
 var (
 	quote = [...]byte{'"'}
+
+	errInvalidQuote = errors.New("jutil: invalid quoted string")
 )
@@ -59,3 +59,41 @@ func TestQuoteString(t *testing.T) {
 		}
 	}
 }
+
+func TestUnquoteString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  `""`,
+			out: "",
+		},
+		{
+			in:  `"Hello World!"`,
+			out: "Hello World!",
+		},
+		{
+			in:  `"Hello\"World!"`,
+			out: "Hello\"World!",
+		},
+		{
+			in:  `"Hello\/World!\n"`,
+			out: "Hello/World!\n",
+		},
+	}
+
+	for _, test := range tests {
+		if s, err := UnquoteString(test.in); err != nil {
+			t.Errorf("%#v: %s", test.in, err)
+		} else if s != test.out {
+			t.Errorf("%#v: invalid unquoted string: %#v != %#v", test.in, test.out, s)
+		}
+	}
+
+	for _, test := range []string{``, `"`, `Hello`, `"Hello`, `Hello"`} {
+		if _, err := UnquoteString(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
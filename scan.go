@@ -0,0 +1,387 @@
+package jutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned by the functions of the jutil package that read raw
+// JSON content when the input is malformed.
+type SyntaxError struct {
+	// The byte offset in the input where the error was detected.
+	Offset int
+
+	// The line and column (in bytes) where the error was detected, both start
+	// at 1.
+	Line   int
+	Column int
+
+	// The unexpected character found at Offset, zero if the error was caused
+	// by reaching the end of the input.
+	Char byte
+
+	// Descriptions of the tokens that would have been valid at Offset, it may
+	// be empty if the error isn't about an unexpected token.
+	Expected []string
+
+	// A description of the problem.
+	Msg string
+}
+
+// Error satisfies the error interface.
+func (e *SyntaxError) Error() string {
+	s := fmt.Sprintf("jutil: syntax error at line %d, column %d (offset %d): %s", e.Line, e.Column, e.Offset, e.Msg)
+	if len(e.Expected) != 0 {
+		s += " (expected " + strings.Join(e.Expected, ", ") + ")"
+	}
+	return s
+}
+
+// newSyntaxError creates a syntax error for offset i of the input b, the line
+// and column are only computed when an error occurs so the scanning functions
+// don't have to keep track of them.
+func newSyntaxError(b []byte, i int, msg string) *SyntaxError {
+	if i > len(b) {
+		i = len(b)
+	}
+	line := 1 + bytes.Count(b[:i], newline[:])
+	column := 1 + i - (bytes.LastIndexByte(b[:i], '\n') + 1)
+	return &SyntaxError{Offset: i, Line: line, Column: column, Msg: msg}
+}
+
+// syntaxContext describes the location of a syntax error in the grammar.
+type syntaxContext struct {
+	msg      string
+	expected []string
+}
+
+var (
+	ctxValue        = &syntaxContext{"looking for beginning of value", []string{"'{'", "'['", "string", "number", "true", "false", "null"}}
+	ctxValueOrEnd   = &syntaxContext{"looking for beginning of value", []string{"'{'", "'['", "']'", "string", "number", "true", "false", "null"}}
+	ctxKey          = &syntaxContext{"looking for beginning of object key string", []string{"string"}}
+	ctxKeyOrEnd     = &syntaxContext{"looking for beginning of object key string", []string{"string", "'}'"}}
+	ctxColon        = &syntaxContext{"after object key", []string{"':'"}}
+	ctxObjectNext   = &syntaxContext{"after object key:value pair", []string{"','", "'}'"}}
+	ctxArrayNext    = &syntaxContext{"after array element", []string{"','", "']'"}}
+	ctxEnd          = &syntaxContext{"after top-level value", []string{"end of input"}}
+	ctxStringEscape = &syntaxContext{"in string escape code", []string{`'"'`, `'\\'`, "'/'", "'b'", "'f'", "'n'", "'r'", "'t'", "'u'"}}
+	ctxStringHex    = &syntaxContext{"in \\u hexadecimal character escape", []string{"hexadecimal digit"}}
+	ctxString       = &syntaxContext{"in string literal", []string{"'\"'"}}
+	ctxNumber       = &syntaxContext{"in numeric literal", []string{"digit"}}
+	ctxFraction     = &syntaxContext{"after decimal point in numeric literal", []string{"digit"}}
+	ctxExponent     = &syntaxContext{"in exponent of numeric literal", []string{"digit"}}
+)
+
+// The functions below implement the low-level scanning of raw JSON, they take
+// the input and the offset of the value to scan, and return the offset of the
+// first byte after the value.
+
+func skipSpaces(b []byte, i int) int {
+	if i < len(b) && b[i] > ' ' {
+		return i // fast path for compact content
+	}
+	for i < len(b) {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func skipValue(b []byte, i int) (int, error) {
+	return skipValueDepth(b, i, 0)
+}
+
+func skipValueDepth(b []byte, i int, depth int) (int, error) {
+	if i >= len(b) {
+		return i, syntaxErrorChar(b, i, ctxValue)
+	}
+
+	switch c := b[i]; c {
+	case '{':
+		return skipObject(b, i, depth+1)
+	case '[':
+		return skipArray(b, i, depth+1)
+	case '"':
+		return scanString(b, i)
+	case 't':
+		return scanLiteral(b, i, "true")
+	case 'f':
+		return scanLiteral(b, i, "false")
+	case 'n':
+		return scanLiteral(b, i, "null")
+	default:
+		if c == '-' || (c >= '0' && c <= '9') {
+			return scanNumber(b, i)
+		}
+		return i, syntaxErrorChar(b, i, ctxValue)
+	}
+}
+
+func skipObject(b []byte, i int, depth int) (int, error) {
+	var err error
+
+	if depth > maxDepth {
+		return i, newSyntaxError(b, i, "exceeded max depth")
+	}
+
+	if i = skipSpaces(b, i+1); i < len(b) && b[i] == '}' {
+		return i + 1, nil
+	}
+
+	for ctx := ctxKeyOrEnd; ; ctx = ctxKey {
+		if i >= len(b) || b[i] != '"' {
+			return i, syntaxErrorChar(b, i, ctx)
+		}
+		if i, err = scanString(b, i); err != nil {
+			return i, err
+		}
+		if i = skipSpaces(b, i); i >= len(b) || b[i] != ':' {
+			return i, syntaxErrorChar(b, i, ctxColon)
+		}
+		if i, err = skipValueDepth(b, skipSpaces(b, i+1), depth); err != nil {
+			return i, err
+		}
+		if i = skipSpaces(b, i); i >= len(b) {
+			return i, syntaxErrorChar(b, i, ctxObjectNext)
+		}
+		switch b[i] {
+		case ',':
+			i = skipSpaces(b, i+1)
+		case '}':
+			return i + 1, nil
+		default:
+			return i, syntaxErrorChar(b, i, ctxObjectNext)
+		}
+	}
+}
+
+func skipArray(b []byte, i int, depth int) (int, error) {
+	var err error
+
+	if depth > maxDepth {
+		return i, newSyntaxError(b, i, "exceeded max depth")
+	}
+
+	if i = skipSpaces(b, i+1); i < len(b) && b[i] == ']' {
+		return i + 1, nil
+	}
+
+	for {
+		if i >= len(b) {
+			return i, syntaxErrorChar(b, i, ctxValueOrEnd)
+		}
+		if i, err = skipValueDepth(b, i, depth); err != nil {
+			return i, err
+		}
+		if i = skipSpaces(b, i); i >= len(b) {
+			return i, syntaxErrorChar(b, i, ctxArrayNext)
+		}
+		switch b[i] {
+		case ',':
+			i = skipSpaces(b, i+1)
+		case ']':
+			return i + 1, nil
+		default:
+			return i, syntaxErrorChar(b, i, ctxArrayNext)
+		}
+	}
+}
+
+func scanString(b []byte, i int) (int, error) {
+	for i++; i < len(b); i++ {
+		// Skip over the bytes that don't need special handling, which is most
+		// of the content of strings.
+		for i < len(b) && plainStringByte[b[i]] {
+			i++
+		}
+		if i == len(b) {
+			break
+		}
+
+		switch c := b[i]; {
+		case c == '"':
+			return i + 1, nil
+
+		case c == '\\':
+			if i++; i >= len(b) {
+				return i, syntaxErrorChar(b, i, ctxStringEscape)
+			}
+			switch b[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				for j := 0; j != 4; j++ {
+					if i++; i >= len(b) || !isHex(b[i]) {
+						return i, syntaxErrorChar(b, i, ctxStringHex)
+					}
+				}
+			default:
+				return i, syntaxErrorChar(b, i, ctxStringEscape)
+			}
+
+		case c < 0x20:
+			return i, syntaxErrorChar(b, i, ctxString)
+		}
+	}
+	return i, syntaxErrorChar(b, i, ctxString)
+}
+
+func scanNumber(b []byte, i int) (int, error) {
+	if b[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i >= len(b):
+		return i, syntaxErrorChar(b, i, ctxNumber)
+	case b[i] == '0':
+		i++
+	case b[i] >= '1' && b[i] <= '9':
+		i = skipDigits(b, i+1)
+	default:
+		return i, syntaxErrorChar(b, i, ctxNumber)
+	}
+
+	if i < len(b) && b[i] == '.' {
+		if i++; i >= len(b) || !isDigit(b[i]) {
+			return i, syntaxErrorChar(b, i, ctxFraction)
+		}
+		i = skipDigits(b, i+1)
+	}
+
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		if i++; i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if i >= len(b) || !isDigit(b[i]) {
+			return i, syntaxErrorChar(b, i, ctxExponent)
+		}
+		i = skipDigits(b, i+1)
+	}
+
+	return i, nil
+}
+
+// isValidNumber returns true if s is a valid JSON number, it follows the rules
+// of scanNumber but works on strings so the values of json.Number can be
+// checked without being copied.
+func isValidNumber(s string) bool {
+	i := 0
+
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i >= len(s):
+		return false
+	case s[i] == '0':
+		i++
+	case s[i] >= '1' && s[i] <= '9':
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+		}
+	default:
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		if i++; i >= len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		if i++; i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i >= len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+		}
+	}
+
+	return i == len(s)
+}
+
+func scanLiteral(b []byte, i int, lit string) (int, error) {
+	for j := 0; j != len(lit); j++ {
+		if i+j >= len(b) || b[i+j] != lit[j] {
+			return i + j, syntaxErrorChar(b, i+j, &syntaxContext{
+				msg:      "in literal " + lit + " (expecting '" + lit[j:j+1] + "')",
+				expected: []string{"'" + lit[j:j+1] + "'"},
+			})
+		}
+	}
+	return i + len(lit), nil
+}
+
+func skipDigits(b []byte, i int) int {
+	for i < len(b) && isDigit(b[i]) {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func syntaxErrorEOF(b []byte, i int) error {
+	return newSyntaxError(b, i, "unexpected end of JSON input")
+}
+
+func syntaxErrorChar(b []byte, i int, ctx *syntaxContext) error {
+	var e *SyntaxError
+
+	if i >= len(b) {
+		e = newSyntaxError(b, i, "unexpected end of JSON input")
+	} else {
+		e = newSyntaxError(b, i, fmt.Sprintf("invalid character %s %s", quoteChar(b[i]), ctx.msg))
+		e.Char = b[i]
+	}
+
+	e.Expected = ctx.expected
+	return e
+}
+
+// quoteChar formats c the way encoding/json does in its syntax errors.
+func quoteChar(c byte) string {
+	switch c {
+	case '\'':
+		return `'\''`
+	case '"':
+		return `'"'`
+	}
+	s := fmt.Sprintf("%q", string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
+
+const (
+	// maxDepth is the maximum nesting depth of values accepted when scanning
+	// raw JSON, it's the same limit as the one of the encoding/json package.
+	maxDepth = 10000
+)
+
+var (
+	newline = [...]byte{'\n'}
+
+	// plainStringByte is a lookup table of the bytes that can appear as-is in
+	// a JSON string.
+	plainStringByte = func() (t [256]bool) {
+		for i := range t {
+			t[i] = i >= 0x20 && i != '"' && i != '\\'
+		}
+		return
+	}()
+)
@@ -0,0 +1,443 @@
+package jutil
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the URI of the JSON Schema dialect generated by Schema.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaTag represents the annotations that can be attached to a struct field
+// to document it in a JSON Schema.
+type SchemaTag struct {
+	// Description is set as the `description` keyword of the field schema.
+	Description string
+
+	// Format is set as the `format` keyword of the field schema.
+	Format string
+}
+
+// SchemaHook is the signature of functions used by MakeSchema to extract
+// annotations from struct fields.
+type SchemaHook func(reflect.StructField) SchemaTag
+
+// ParseSchemaTag is the default SchemaHook, it reads the `description` and
+// `format` tags of a struct field.
+func ParseSchemaTag(f reflect.StructField) SchemaTag {
+	return SchemaTag{
+		Description: f.Tag.Get("description"),
+		Format:      f.Tag.Get("format"),
+	}
+}
+
+// Schema generates a draft 2020-12 JSON Schema describing the JSON
+// representation of values of type t, using ParseSchemaTag to annotate the
+// struct fields.
+func Schema(t reflect.Type) ([]byte, error) {
+	return MakeSchema(t, ParseSchemaTag)
+}
+
+// MakeSchema behaves like Schema but uses hook to extract the annotations of
+// struct fields, a nil hook disables annotations.
+//
+// Struct fields are described by looking up the type with LookupStruct, fields
+// without `omitempty` are listed as required, pointers are nullable, and named
+// struct types are placed in `$defs` so nested and recursive types can be
+// referenced.
+func MakeSchema(t reflect.Type, hook SchemaHook) ([]byte, error) {
+	g := schemaGenerator{
+		hook:  hook,
+		names: make(map[reflect.Type]string),
+		types: make(map[string]reflect.Type),
+		defs:  make(map[string]*schema),
+	}
+
+	root, err := g.schemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &bytes.Buffer{}
+	w.WriteString(`{"$schema":`)
+	writeString(w, SchemaDialect)
+	root.writeFields(w)
+
+	if len(g.defs) != 0 {
+		names := make([]string, 0, len(g.defs))
+		for name := range g.defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.WriteString(`,"$defs":{`)
+		for i, name := range names {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			writeString(w, name)
+			w.WriteByte(':')
+			g.defs[name].write(w)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte('}')
+	return w.Bytes(), nil
+}
+
+type schemaGenerator struct {
+	hook  SchemaHook
+	names map[reflect.Type]string
+	types map[string]reflect.Type
+	defs  map[string]*schema
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) (s *schema, err error) {
+	switch {
+	case t == timeType:
+		return &schema{types: []string{"string"}, format: "date-time"}, nil
+
+	case t == numberType:
+		return &schema{types: []string{"number"}}, nil
+
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// The representation of the value cannot be known in advance.
+		return &schema{}, nil
+
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &schema{types: []string{"string"}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		s = &schema{types: []string{"boolean"}}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = &schema{types: []string{"integer"}}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = &schema{types: []string{"integer"}, minimum: "0"}
+
+	case reflect.Float32, reflect.Float64:
+		s = &schema{types: []string{"number"}}
+
+	case reflect.String:
+		s = &schema{types: []string{"string"}}
+
+	case reflect.Interface:
+		s = &schema{}
+
+	case reflect.Ptr:
+		if s, err = g.schemaOf(t.Elem()); err == nil {
+			s = s.nullable()
+		}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = &schema{types: []string{"string", "null"}, contentEncoding: "base64"}
+		} else if s, err = g.arrayOf(t); err == nil {
+			s = s.nullable()
+		}
+
+	case reflect.Array:
+		if s, err = g.arrayOf(t); err == nil {
+			n := strconv.Itoa(t.Len())
+			s.minItems, s.maxItems = n, n
+		}
+
+	case reflect.Map:
+		if s, err = g.mapOf(t); err == nil {
+			s = s.nullable()
+		}
+
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			s, err = g.structOf(t)
+		} else {
+			s, err = g.defOf(t)
+		}
+
+	default:
+		err = &json.UnsupportedTypeError{Type: t}
+	}
+
+	return
+}
+
+func (g *schemaGenerator) arrayOf(t reflect.Type) (*schema, error) {
+	items, err := g.schemaOf(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &schema{types: []string{"array"}, items: items}, nil
+}
+
+func (g *schemaGenerator) mapOf(t reflect.Type) (*schema, error) {
+	switch k := t.Key(); {
+	case k.Kind() == reflect.String:
+	case k.Implements(textMarshalerType):
+	default:
+		switch k.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			return nil, &json.UnsupportedTypeError{Type: t}
+		}
+	}
+
+	elem, err := g.schemaOf(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &schema{types: []string{"object"}, additionalProperties: elem}, nil
+}
+
+func (g *schemaGenerator) defOf(t reflect.Type) (*schema, error) {
+	name, ok := g.names[t]
+
+	if !ok {
+		name = g.defName(t)
+		g.names[t] = name
+		g.types[name] = t
+		g.defs[name] = &schema{} // placeholder for recursive types
+
+		s, err := g.structOf(t)
+		if err != nil {
+			return nil, err
+		}
+		g.defs[name] = s
+	}
+
+	return &schema{ref: "#/$defs/" + escapePointerToken(name)}, nil
+}
+
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	name := t.Name()
+
+	if _, taken := g.types[name]; taken {
+		name = strings.Replace(t.PkgPath(), "/", ".", -1) + "." + name
+	}
+
+	for i := 2; ; i++ {
+		if _, taken := g.types[name]; !taken {
+			return name
+		}
+		name = t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGenerator) structOf(t reflect.Type) (*schema, error) {
+	s := &schema{types: []string{"object"}, properties: []schemaProperty{}}
+
+	for _, f := range LookupStruct(t) {
+		sf := t.FieldByIndex(f.Index)
+
+		p, err := g.schemaOf(sf.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if g.hook != nil {
+			tag := g.hook(sf)
+			if len(tag.Description) != 0 || len(tag.Format) != 0 {
+				p = p.annotate(tag)
+			}
+		}
+
+		s.properties = append(s.properties, schemaProperty{name: f.Name, schema: p})
+
+		if !f.Omitempty && !f.Omitzero {
+			s.required = append(s.required, f.Name)
+		}
+	}
+
+	return s, nil
+}
+
+type schema struct {
+	ref                  string
+	types                []string
+	format               string
+	description          string
+	contentEncoding      string
+	minimum              string
+	minItems             string
+	maxItems             string
+	anyOf                []*schema
+	items                *schema
+	additionalProperties *schema
+	properties           []schemaProperty
+	required             []string
+}
+
+type schemaProperty struct {
+	name   string
+	schema *schema
+}
+
+func (s *schema) nullable() *schema {
+	switch {
+	case len(s.ref) != 0 || len(s.anyOf) != 0:
+		return &schema{anyOf: []*schema{s, {types: []string{"null"}}}}
+
+	case len(s.types) == 0: // any value, already includes null
+		return s
+	}
+
+	for _, t := range s.types {
+		if t == "null" {
+			return s
+		}
+	}
+
+	c := *s
+	c.types = append(append(make([]string, 0, len(s.types)+1), s.types...), "null")
+	return &c
+}
+
+func (s *schema) annotate(tag SchemaTag) *schema {
+	c := *s
+	if len(tag.Description) != 0 {
+		c.description = tag.Description
+	}
+	if len(tag.Format) != 0 {
+		c.format = tag.Format
+	}
+	return &c
+}
+
+func (s *schema) write(w *bytes.Buffer) {
+	n := w.Len()
+	s.writeFields(w)
+
+	if w.Len() == n {
+		w.WriteString("{}")
+		return
+	}
+
+	// The comma written before the first field becomes the opening brace.
+	w.Bytes()[n] = '{'
+	w.WriteByte('}')
+}
+
+// writeFields outputs the keywords of s, each of them preceded by a comma.
+func (s *schema) writeFields(w *bytes.Buffer) {
+	if len(s.ref) != 0 {
+		writeSchemaString(w, "$ref", s.ref)
+	}
+
+	if len(s.description) != 0 {
+		writeSchemaString(w, "description", s.description)
+	}
+
+	switch len(s.types) {
+	case 0:
+	case 1:
+		writeSchemaString(w, "type", s.types[0])
+	default:
+		writeSchemaStrings(w, "type", s.types)
+	}
+
+	if len(s.format) != 0 {
+		writeSchemaString(w, "format", s.format)
+	}
+
+	if len(s.contentEncoding) != 0 {
+		writeSchemaString(w, "contentEncoding", s.contentEncoding)
+	}
+
+	if len(s.minimum) != 0 {
+		writeSchemaNumber(w, "minimum", s.minimum)
+	}
+
+	if len(s.anyOf) != 0 {
+		w.WriteString(`,"anyOf":[`)
+		for i, a := range s.anyOf {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			a.write(w)
+		}
+		w.WriteByte(']')
+	}
+
+	if s.items != nil {
+		w.WriteString(`,"items":`)
+		s.items.write(w)
+	}
+
+	if len(s.minItems) != 0 {
+		writeSchemaNumber(w, "minItems", s.minItems)
+	}
+
+	if len(s.maxItems) != 0 {
+		writeSchemaNumber(w, "maxItems", s.maxItems)
+	}
+
+	if s.properties != nil {
+		w.WriteString(`,"properties":{`)
+		for i, p := range s.properties {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			writeString(w, p.name)
+			w.WriteByte(':')
+			p.schema.write(w)
+		}
+		w.WriteByte('}')
+	}
+
+	if len(s.required) != 0 {
+		writeSchemaStrings(w, "required", s.required)
+	}
+
+	if s.additionalProperties != nil {
+		w.WriteString(`,"additionalProperties":`)
+		s.additionalProperties.write(w)
+	}
+}
+
+func writeSchemaString(w *bytes.Buffer, key string, value string) {
+	w.WriteString(`,"` + key + `":`)
+	writeString(w, value)
+}
+
+func writeSchemaStrings(w *bytes.Buffer, key string, values []string) {
+	w.WriteString(`,"` + key + `":[`)
+	for i, v := range values {
+		if i != 0 {
+			w.WriteByte(',')
+		}
+		writeString(w, v)
+	}
+	w.WriteByte(']')
+}
+
+func writeSchemaNumber(w *bytes.Buffer, key string, value string) {
+	w.WriteString(`,"` + key + `":` + value)
+}
+
+// escapePointerToken escapes a reference token so it can be used in a JSON
+// Pointer.
+func escapePointerToken(s string) string {
+	if strings.IndexByte(s, '~') < 0 && strings.IndexByte(s, '/') < 0 {
+		return s
+	}
+	return pointerEscaper.Replace(s)
+}
+
+var (
+	pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+	timeType          = reflect.TypeOf(time.Time{})
+	numberType        = reflect.TypeOf(json.Number(""))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
@@ -0,0 +1,175 @@
+package jutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaTestEvent struct {
+	ID       string            `json:"id" description:"Unique identifier of the event."`
+	Time     time.Time         `json:"time"`
+	Email    *string           `json:"email,omitempty" format:"email"`
+	Count    uint              `json:"count"`
+	Score    float64           `json:"score,omitempty"`
+	Tags     []string          `json:"tags"`
+	Props    map[string]int    `json:"props,omitempty"`
+	Payload  []byte            `json:"payload,omitempty"`
+	Any      interface{}       `json:"any,omitempty"`
+	Owner    *schemaTestUser   `json:"owner"`
+	Users    [2]schemaTestUser `json:"users"`
+	Skipped  int               `json:"-"`
+	internal int
+}
+
+type schemaTestUser struct {
+	Name    string            `json:"name"`
+	Friends []*schemaTestUser `json:"friends,omitempty"`
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		t reflect.Type
+		s string
+	}{
+		{
+			t: reflect.TypeOf(0),
+			s: `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"integer"}`,
+		},
+		{
+			t: reflect.TypeOf((*bool)(nil)),
+			s: `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":["boolean","null"]}`,
+		},
+		{
+			t: reflect.TypeOf(struct {
+				A int    `json:"a"`
+				B string `json:",omitempty"`
+			}{}),
+			s: `{
+				"$schema":"https://json-schema.org/draft/2020-12/schema",
+				"type":"object",
+				"properties":{"a":{"type":"integer"},"B":{"type":"string"}},
+				"required":["a"]
+			}`,
+		},
+		{
+			t: reflect.TypeOf(schemaTestEvent{}),
+			s: `{
+				"$schema":"https://json-schema.org/draft/2020-12/schema",
+				"$ref":"#/$defs/schemaTestEvent",
+				"$defs":{
+					"schemaTestEvent":{
+						"type":"object",
+						"properties":{
+							"id":{"description":"Unique identifier of the event.","type":"string"},
+							"time":{"type":"string","format":"date-time"},
+							"email":{"type":["string","null"],"format":"email"},
+							"count":{"type":"integer","minimum":0},
+							"score":{"type":"number"},
+							"tags":{"type":["array","null"],"items":{"type":"string"}},
+							"props":{"type":["object","null"],"additionalProperties":{"type":"integer"}},
+							"payload":{"type":["string","null"],"contentEncoding":"base64"},
+							"any":{},
+							"owner":{"anyOf":[{"$ref":"#/$defs/schemaTestUser"},{"type":"null"}]},
+							"users":{"type":"array","items":{"$ref":"#/$defs/schemaTestUser"},"minItems":2,"maxItems":2}
+						},
+						"required":["id","time","count","tags","owner","users"]
+					},
+					"schemaTestUser":{
+						"type":"object",
+						"properties":{
+							"name":{"type":"string"},
+							"friends":{
+								"type":["array","null"],
+								"items":{"anyOf":[{"$ref":"#/$defs/schemaTestUser"},{"type":"null"}]}
+							}
+						},
+						"required":["name"]
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := Schema(test.t)
+		if err != nil {
+			t.Errorf("%s: %s", test.t, err)
+			continue
+		}
+
+		var expect interface{}
+		var found interface{}
+		json.Unmarshal([]byte(test.s), &expect)
+
+		if err := json.Unmarshal(b, &found); err != nil {
+			t.Errorf("%s: invalid schema: %s: %s", test.t, err, string(b))
+		} else if !reflect.DeepEqual(expect, found) {
+			t.Errorf("%s: invalid schema: %s", test.t, string(b))
+		}
+	}
+}
+
+func TestSchemaHook(t *testing.T) {
+	type T struct {
+		A int `doc:"the answer"`
+	}
+
+	b, err := MakeSchema(reflect.TypeOf(T{}), func(f reflect.StructField) SchemaTag {
+		return SchemaTag{Description: f.Tag.Get("doc")}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Description string
+			}
+		} `json:"$defs"`
+	}
+	json.Unmarshal(b, &s)
+
+	if d := s.Defs["T"].Properties["A"].Description; d != "the answer" {
+		t.Errorf("invalid description: %#v: %s", d, string(b))
+	}
+}
+
+func TestSchemaEscaping(t *testing.T) {
+	type T struct {
+		A int `json:"a/b" description:"tab\x01here\v" format:"<\x1f>"`
+	}
+
+	b, err := Schema(reflect.TypeOf(T{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !json.Valid(b) {
+		t.Fatalf("invalid schema: %q", b)
+	}
+
+	for _, s := range []string{`"https://json-schema.org/`, `"#/$defs/T"`, `"a/b"`, `"tab\u0001here\u000b"`} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("missing %s in schema: %s", s, b)
+		}
+	}
+}
+
+func TestSchemaUnsupportedType(t *testing.T) {
+	tests := []interface{}{
+		make(chan int),
+		func() {},
+		map[bool]int{},
+		struct{ C complex64 }{},
+	}
+
+	for _, test := range tests {
+		if _, err := Schema(reflect.TypeOf(test)); err == nil {
+			t.Errorf("%T: expected an error", test)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package jutil
+
+import (
+	"bytes"
+	"io"
+)
+
+// Stats carries structural information about a JSON document.
+type Stats struct {
+	// The length of the document once compacted, which is the length of its
+	// representation without insignificant whitespaces.
+	Length int
+
+	// The maximum nesting depth of objects and arrays, scalar top-level
+	// values have a depth of zero.
+	Depth int
+
+	// The total number of object keys.
+	Keys int
+
+	// The total number of array elements.
+	Elements int
+
+	// The number of values of each type in the document, object keys are not
+	// counted as strings.
+	Objects  int
+	Arrays   int
+	Strings  int
+	Numbers  int
+	Booleans int
+	Nulls    int
+}
+
+// LengthReader reads a JSON document from r and returns statistics about its
+// structure, including the length of its compacted representation.
+//
+// The document is processed in chunks as it's read, the memory used by the
+// function is bounded by the size of the largest token rather than the size
+// of the document. The function returns a *SyntaxError if the content read
+// from r isn't a single valid JSON value.
+func LengthReader(r io.Reader) (Stats, error) {
+	s := statsReader{r: r, buf: make([]byte, 0, 4096)}
+	err := s.run()
+	return s.stats, err
+}
+
+type statsReader struct {
+	r     io.Reader
+	buf   []byte
+	tok   Tokenizer
+	eof   bool
+	stats Stats
+
+	// Number of values nested in objects and arrays, each of them is either
+	// the value of an object member or an array element.
+	nested int
+
+	// Position of the buffer in the document, used to report syntax errors
+	// relative to the beginning of the document.
+	offset int
+	line   int
+	column int
+}
+
+func (s *statsReader) run() error {
+	t := &s.tok
+	t.Reset(s.buf)
+	values := 0
+
+	for {
+		saved := *t
+
+		if !t.Next() {
+			if s.incomplete() {
+				*t = saved
+				if err := s.fill(); err != nil {
+					return err
+				}
+				continue
+			}
+			if t.Err != nil {
+				return s.syntaxError(t.Err)
+			}
+			if values == 0 {
+				return s.syntaxError(syntaxErrorEOF(t.input, t.Pos()))
+			}
+			s.stats.Elements = s.nested - s.stats.Keys
+			return nil
+		}
+
+		// Numbers and literals may continue in the next chunk of the input.
+		if t.Pos() == len(t.input) && !s.eof {
+			*t = saved
+			if err := s.fill(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t.Depth == 0 && t.Kind != ObjectEnd && t.Kind != ArrayEnd {
+			if values++; values > 1 {
+				return s.syntaxError(syntaxErrorChar(t.input, t.Offset, ctxEnd))
+			}
+		}
+
+		s.count(t)
+	}
+}
+
+func (s *statsReader) count(t *Tokenizer) {
+	st := &s.stats
+	st.Length += len(t.Value)
+
+	switch t.Kind {
+	case ObjectStart:
+		st.Objects++
+	case ArrayStart:
+		st.Arrays++
+	case String:
+		if t.IsKey {
+			st.Keys++
+			return
+		}
+		st.Strings++
+	case Number:
+		st.Numbers++
+	case True, False:
+		st.Booleans++
+	case Null:
+		st.Nulls++
+	default:
+		return // delimiters are not values
+	}
+
+	if t.Kind == ObjectStart || t.Kind == ArrayStart {
+		if t.Depth+1 > st.Depth {
+			st.Depth = t.Depth + 1
+		}
+	}
+
+	if t.Depth != 0 {
+		s.nested++
+	}
+}
+
+// incomplete returns true if the tokenizer stopped because it reached the end
+// of the buffer while more input may be available.
+func (s *statsReader) incomplete() bool {
+	if s.eof {
+		return false
+	}
+	if s.tok.Err == nil {
+		return true
+	}
+	e, ok := s.tok.Err.(*SyntaxError)
+	return ok && e.Offset >= len(s.tok.input)
+}
+
+// fill discards the bytes of the buffer that were consumed by the tokenizer and
+// reads more input.
+func (s *statsReader) fill() error {
+	t := &s.tok
+	done := s.buf[:t.pos]
+
+	if n := bytes.Count(done, newline[:]); n != 0 {
+		s.line += n
+		s.column = len(done) - (bytes.LastIndexByte(done, '\n') + 1)
+	} else {
+		s.column += len(done)
+	}
+
+	s.offset += t.pos
+	n := copy(s.buf, s.buf[t.pos:])
+	s.buf = s.buf[:n]
+
+	if n == cap(s.buf) {
+		// The token doesn't fit in the buffer.
+		s.buf = append(make([]byte, 0, 2*cap(s.buf)), s.buf...)
+	}
+
+	k, err := s.r.Read(s.buf[n:cap(s.buf)])
+	s.buf = s.buf[:n+k]
+
+	switch err {
+	case nil:
+	case io.EOF:
+		s.eof = true
+	default:
+		return err
+	}
+
+	t.input, t.pos, t.Err = s.buf, 0, nil
+	return nil
+}
+
+// syntaxError adjusts the position of syntax errors reported by the tokenizer
+// to be relative to the beginning of the document.
+func (s *statsReader) syntaxError(err error) error {
+	if e, ok := err.(*SyntaxError); ok {
+		if e.Line == 1 {
+			e.Column += s.column
+		}
+		e.Line += s.line
+		e.Offset += s.offset
+	}
+	return err
+}
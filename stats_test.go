@@ -0,0 +1,135 @@
+package jutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLengthReader(t *testing.T) {
+	tests := []struct {
+		in    string
+		stats Stats
+	}{
+		{
+			in:    `null`,
+			stats: Stats{Length: 4, Nulls: 1},
+		},
+		{
+			in:    ` -1.5e+10 `,
+			stats: Stats{Length: 8, Numbers: 1},
+		},
+		{
+			in:    `[]`,
+			stats: Stats{Length: 2, Depth: 1, Arrays: 1},
+		},
+		{
+			in: `{
+				"a": [1, 2, {"b": null}],
+				"c": "Hello World!",
+				"d": [[true], false]
+			}`,
+			stats: Stats{
+				Length:   60,
+				Depth:    3,
+				Keys:     4,
+				Elements: 6,
+				Objects:  2,
+				Arrays:   3,
+				Strings:  1,
+				Numbers:  2,
+				Booleans: 2,
+				Nulls:    1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		compact, _ := Compact(nil, []byte(test.in))
+
+		if test.stats.Length != len(compact) {
+			t.Fatalf("%q: invalid test length: %d != %d", test.in, len(compact), test.stats.Length)
+		}
+
+		for _, r := range []io.Reader{
+			strings.NewReader(test.in),
+			iotest.OneByteReader(strings.NewReader(test.in)),
+			iotest.DataErrReader(iotest.HalfReader(strings.NewReader(test.in))),
+		} {
+			if stats, err := LengthReader(r); err != nil {
+				t.Errorf("%q: %s", test.in, err)
+			} else if stats != test.stats {
+				t.Errorf("%q: invalid stats:\n%+v\n%+v", test.in, test.stats, stats)
+			}
+		}
+	}
+}
+
+func TestLengthReaderLongDocument(t *testing.T) {
+	compact, _ := Compact(nil, []byte(longJSONDocument))
+
+	// Long strings force the buffer to grow beyond its initial size.
+	doc := `{"long":"` + strings.Repeat("a", 10000) + `","doc":` + longJSONDocument + `}`
+
+	stats, err := LengthReader(iotest.HalfReader(strings.NewReader(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := len(compact) + 10018; stats.Length != expect {
+		t.Error("invalid length:", stats.Length, "!=", expect)
+	}
+}
+
+func TestLengthReaderError(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+		line   int
+		column int
+	}{
+		{``, 0, 1, 1},
+		{`[1,2`, 4, 1, 5},
+		{`[1,2] 3`, 6, 1, 7},
+		{"[\n  1,\n  2,,\n]", 11, 3, 5},
+		{`{"a":tru}`, 8, 1, 9},
+	}
+
+	for _, test := range tests {
+		_, err := LengthReader(iotest.OneByteReader(strings.NewReader(test.in)))
+
+		e, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected a syntax error but got %v", test.in, err)
+			continue
+		}
+
+		if e.Offset != test.offset || e.Line != test.line || e.Column != test.column {
+			t.Errorf("%q: invalid error location: %d:%d (offset %d) != %d:%d (offset %d)",
+				test.in, test.line, test.column, test.offset, e.Line, e.Column, e.Offset)
+		}
+
+		if v := Validate([]byte(test.in)).(*SyntaxError); v.Offset != e.Offset || v.Line != e.Line || v.Column != e.Column {
+			t.Errorf("%q: error location doesn't match Validate: %s", test.in, v)
+		}
+	}
+
+	ioErr := errors.New("read error")
+
+	if _, err := LengthReader(io.MultiReader(bytes.NewReader([]byte(`[1,`)), iotest.ErrReader(ioErr))); err != ioErr {
+		t.Error("expected the read error but got", err)
+	}
+}
+
+func BenchmarkLengthReader(b *testing.B) {
+	r := strings.NewReader(longJSONDocument)
+	b.SetBytes(int64(len(longJSONDocument)))
+
+	for i := 0; i != b.N; i++ {
+		r.Reset(longJSONDocument)
+		LengthReader(r)
+	}
+}
@@ -37,6 +37,15 @@ func MakeStruct(t reflect.Type) Struct {
 	return s
 }
 
+func (s Struct) lookup(name string) (StructField, bool) {
+	for _, f := range s {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return StructField{}, false
+}
+
 // StructField represents a single field of a struct and carries information
 // useful to the algorithms of the jutil package.
 type StructField struct {
@@ -49,8 +58,35 @@ type StructField struct {
 	// True if the field has to be omitted when it has an empty value.
 	Omitempty bool
 
+	// True if the field has to be omitted when it has a zero value.
+	Omitzero bool
+
 	// True if the field should be skipped entirely.
 	Skip bool
+
+	// IsEmpty and IsZero are functions specialized for the type of the field
+	// which tell whether its values are empty (see IsEmptyValue) or zero (see
+	// IsZeroValue). They are safe to call with any value of the field type,
+	// when nil the generic checks are used instead.
+	//
+	// Because of these fields StructField values can't be compared with the
+	// == operator.
+	IsEmpty func(reflect.Value) bool
+	IsZero  func(reflect.Value) bool
+}
+
+// Omit returns true if v, a value of the field, should be omitted from the
+// JSON representation of the struct because of the field's omitempty or
+// omitzero options.
+func (f StructField) Omit(v reflect.Value) bool {
+	isEmpty, isZero := f.IsEmpty, f.IsZero
+	if isEmpty == nil {
+		isEmpty = isEmptyField
+	}
+	if isZero == nil {
+		isZero = isZeroValue
+	}
+	return (f.Omitempty && isEmpty(v)) || (f.Omitzero && isZero(v))
 }
 
 // MakeStructField takes a Go struct field as argument argument and returns its
@@ -62,7 +98,10 @@ func MakeStructField(f reflect.StructField) StructField {
 		Index:     f.Index,
 		Name:      tag.Name,
 		Omitempty: tag.Omitempty,
+		Omitzero:  tag.Omitzero,
 		Skip:      tag.Skip,
+		IsEmpty:   emptyFunc(f.Type),
+		IsZero:    zeroFunc(f.Type),
 	}
 
 	if len(f.PkgPath) != 0 && !f.Anonymous { // unexported
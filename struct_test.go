@@ -3,6 +3,7 @@ package jutil
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMakeStructField(t *testing.T) {
@@ -31,8 +32,79 @@ func TestMakeStructField(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		if f := MakeStructField(test.s); !reflect.DeepEqual(test.f, f) {
+		f := MakeStructField(test.s)
+
+		if f.IsEmpty == nil || f.IsZero == nil {
+			t.Errorf("%#v: missing emptiness checks", f)
+		}
+
+		// Functions can't be compared by reflect.DeepEqual.
+		f.IsEmpty, f.IsZero = nil, nil
+
+		if !reflect.DeepEqual(test.f, f) {
 			t.Errorf("%#v != %#v", test.f, f)
 		}
 	}
 }
+
+func TestStructFieldOmit(t *testing.T) {
+	type T struct {
+		A int       `json:",omitempty"`
+		B []int     `json:",omitzero"`
+		C emptier   `json:",omitempty"`
+		D *zeroer   `json:",omitzero"`
+		E time.Time `json:",omitempty"`
+		F string    `json:",omitempty,omitzero"`
+		G int
+	}
+
+	tests := []struct {
+		value T
+		omit  []bool
+	}{
+		{T{}, []bool{true, true, false, true, false, true, false}},
+		{T{A: 1, B: []int{}, C: emptier{true}, D: &zeroer{-1}, F: "F"}, []bool{false, false, true, true, false, false, false}},
+	}
+
+	s := MakeStruct(reflect.TypeOf(T{}))
+
+	// Fields built as literals have no specialized functions.
+	l := make(Struct, len(s))
+	for i, f := range s {
+		l[i] = StructField{
+			Index:     f.Index,
+			Name:      f.Name,
+			Omitempty: f.Omitempty,
+			Omitzero:  f.Omitzero,
+		}
+	}
+
+	for _, test := range tests {
+		v := reflect.ValueOf(test.value)
+
+		for i := range s {
+			for _, f := range []StructField{s[i], l[i]} {
+				if omit := f.Omit(v.FieldByIndex(f.Index)); omit != test.omit[i] {
+					t.Errorf("%#v: %s: invalid omission (IsEmpty=%t): %t", test.value, f.Name, f.IsEmpty != nil, omit)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkStructFieldOmit(b *testing.B) {
+	type T struct {
+		A int    `json:",omitempty"`
+		B string `json:",omitempty"`
+		C []int  `json:",omitempty"`
+	}
+
+	s := LookupStruct(reflect.TypeOf(T{}))
+	v := reflect.ValueOf(T{A: 1})
+
+	for i := 0; i != b.N; i++ {
+		for _, f := range s {
+			f.Omit(v.Field(f.Index[0]))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package jutil
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// The functions in this file find the bytes that have to be escaped in JSON
+// strings (following the rules of WriteEscaped), they are shared by the length
+// computation and the escaping functions.
+//
+// The input is processed 8 bytes at a time using SWAR (SIMD within a register)
+// techniques, each word is checked for bytes that may need escaping with a
+// handful of arithmetic operations, and only the words where a candidate was
+// found are inspected byte by byte.
+//
+// escapeIndex and escapeIndexString are defined in the architecture specific
+// files, on amd64 (with AVX2) and arm64 they process long inputs with vector
+// instructions and fall back to the SWAR functions below otherwise. Building
+// with the purego tag disables the assembly code.
+
+// escapeIndexSWAR returns the index of the first byte of b that has to be
+// escaped, or -1 if there are none.
+func escapeIndexSWAR(b []byte) int {
+	i := 0
+
+	for ; i+8 <= len(b); i += 8 {
+		if m := escapeMask(binary.LittleEndian.Uint64(b[i:])); m != 0 {
+			for j := i + bits.TrailingZeros64(m)/8; j != i+8; j++ {
+				if escapeChar[b[j]] != 0 {
+					return j
+				}
+			}
+		}
+	}
+
+	for ; i < len(b); i++ {
+		if escapeChar[b[i]] != 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// escapeIndexStringSWAR is the equivalent of escapeIndexSWAR for strings.
+func escapeIndexStringSWAR(s string) int {
+	i := 0
+
+	for ; i+8 <= len(s); i += 8 {
+		x := uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+			uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+
+		if m := escapeMask(x); m != 0 {
+			for j := i + bits.TrailingZeros64(m)/8; j != i+8; j++ {
+				if escapeChar[s[j]] != 0 {
+					return j
+				}
+			}
+		}
+	}
+
+	for ; i < len(s); i++ {
+		if escapeChar[s[i]] != 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// escapeMask returns a word where the high bit of each byte is set if the
+// corresponding byte of x may have to be escaped.
+//
+// The mask is exact up to the first candidate byte, the following bytes may
+// be false positives (the borrow of the subtractions propagates to the upper
+// bytes), and the range check also matches 0x0E and 0x0F, so the callers
+// must confirm the candidates.
+func escapeMask(x uint64) uint64 {
+	return hasZeroByte(x^(lsb*'"')) |
+		hasZeroByte(x^(lsb*'\\')) |
+		hasZeroByte(x^(lsb*'/')) |
+		hasZeroByte((x&(lsb*0xF8))^(lsb*0x08)) // \b \t \n \v \f \r
+}
+
+func hasZeroByte(x uint64) uint64 {
+	return (x - lsb) &^ x & msb
+}
+
+const (
+	lsb = 0x0101010101010101
+	msb = 0x8080808080808080
+)
+
+// escapeChar maps the bytes that must be escaped to the character following
+// the backslash in their escape sequence, zero means that the byte is written
+// as-is.
+var escapeChar = [256]byte{
+	'"':  '"',
+	'/':  '/',
+	'\\': '\\',
+	'\n': 'n',
+	'\t': 't',
+	'\r': 'r',
+	'\v': 'v',
+	'\b': 'b',
+	'\f': 'f',
+}
@@ -0,0 +1,37 @@
+//go:build !purego
+
+package jutil
+
+// hasAVX2 is true when the CPU and the operating system support the AVX2
+// instructions.
+var hasAVX2 = cpuHasAVX2()
+
+// escapeIndex returns the index of the first byte of b that has to be escaped,
+// or -1 if there are none.
+//
+// Inputs of at least 32 bytes are processed 32 bytes at a time with AVX2
+// instructions when they are available.
+func escapeIndex(b []byte) int {
+	if hasAVX2 && len(b) >= 32 {
+		return escapeIndexAVX2(b)
+	}
+	return escapeIndexSWAR(b)
+}
+
+// escapeIndexString is the equivalent of escapeIndex for strings.
+func escapeIndexString(s string) int {
+	if hasAVX2 && len(s) >= 32 {
+		return escapeIndexStringAVX2(s)
+	}
+	return escapeIndexStringSWAR(s)
+}
+
+// The AVX2 functions require inputs of at least 32 bytes.
+
+//go:noescape
+func escapeIndexAVX2(b []byte) int
+
+//go:noescape
+func escapeIndexStringAVX2(s string) int
+
+func cpuHasAVX2() bool
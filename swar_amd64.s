@@ -0,0 +1,119 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func escapeIndexAVX2(b []byte) int
+TEXT ·escapeIndexAVX2(SB), NOSPLIT, $0-32
+	MOVQ b_base+0(FP), SI
+	MOVQ b_len+8(FP), BX
+	LEAQ ret+24(FP), DI
+	JMP  escapeIndexBodyAVX2<>(SB)
+
+// func escapeIndexStringAVX2(s string) int
+TEXT ·escapeIndexStringAVX2(SB), NOSPLIT, $0-24
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), BX
+	LEAQ ret+16(FP), DI
+	JMP  escapeIndexBodyAVX2<>(SB)
+
+// escapeIndexBodyAVX2<> takes the address of the input in SI and its length
+// (at least 32) in BX, it writes the index of the first byte that has to be
+// escaped, or -1, to the address in DI.
+//
+// Each block of 32 bytes is compared with '"', '\\' and '/', and the bytes
+// from \b to \r are matched by subtracting 8 and checking that the result is
+// at most 5 as an unsigned byte. The last block overlaps the previous one when
+// the length isn't a multiple of 32, which is fine since the bytes that it
+// checks again had no match.
+TEXT escapeIndexBodyAVX2<>(SB), NOSPLIT, $0-0
+	VPBROADCASTB escapeConstsAVX2<>+0(SB), Y1
+	VPBROADCASTB escapeConstsAVX2<>+1(SB), Y2
+	VPBROADCASTB escapeConstsAVX2<>+2(SB), Y3
+	VPBROADCASTB escapeConstsAVX2<>+3(SB), Y4
+	VPBROADCASTB escapeConstsAVX2<>+4(SB), Y5
+
+	MOVQ SI, R8             // start of the input
+	LEAQ -32(SI)(BX*1), R9  // start of the last block
+	LEAQ (SI)(BX*1), R10    // end of the input
+
+loop:
+	VMOVDQU   (SI), Y0
+	VPCMPEQB  Y0, Y1, Y6
+	VPCMPEQB  Y0, Y2, Y7
+	VPOR      Y6, Y7, Y6
+	VPCMPEQB  Y0, Y3, Y7
+	VPOR      Y6, Y7, Y6
+	VPSUBB    Y4, Y0, Y7
+	VPMINUB   Y5, Y7, Y8
+	VPCMPEQB  Y7, Y8, Y8
+	VPOR      Y6, Y8, Y6
+	VPMOVMSKB Y6, AX
+	TESTL     AX, AX
+	JNZ       found
+
+	ADDQ $32, SI
+	CMPQ SI, R9
+	JBE  loop
+	CMPQ SI, R10
+	JEQ  notfound
+	MOVQ R9, SI
+	JMP  loop
+
+found:
+	BSFL AX, AX
+	SUBQ R8, SI
+	ADDQ SI, AX
+	MOVQ AX, (DI)
+	VZEROUPPER
+	RET
+
+notfound:
+	MOVQ $-1, (DI)
+	VZEROUPPER
+	RET
+
+// escapeConstsAVX2<> holds the bytes broadcast to Y1 to Y5, they are loaded
+// from memory because moving them from general purpose registers uses SSE
+// instructions, which are slow to mix with AVX2 ones.
+DATA  escapeConstsAVX2<>+0(SB)/1, $0x22
+DATA  escapeConstsAVX2<>+1(SB)/1, $0x5c
+DATA  escapeConstsAVX2<>+2(SB)/1, $0x2f
+DATA  escapeConstsAVX2<>+3(SB)/1, $0x08
+DATA  escapeConstsAVX2<>+4(SB)/1, $0x05
+GLOBL escapeConstsAVX2<>(SB), RODATA|NOPTR, $5
+
+// func cpuHasAVX2() bool
+TEXT ·cpuHasAVX2(SB), NOSPLIT, $0-1
+	MOVL $0, AX
+	CPUID
+	CMPL AX, $7
+	JB   no
+
+	// The OSXSAVE (27) and AVX (28) bits of ECX must be set.
+	MOVL  $1, AX
+	XORL  CX, CX
+	CPUID
+	ANDL  $0x18000000, CX
+	CMPL  CX, $0x18000000
+	JNE   no
+
+	// The operating system must save the XMM and YMM registers.
+	XORL   CX, CX
+	XGETBV
+	ANDL   $6, AX
+	CMPL   AX, $6
+	JNE    no
+
+	// The AVX2 bit (5) of EBX.
+	MOVL $7, AX
+	XORL CX, CX
+	CPUID
+	BTL  $5, BX
+	JCC  no
+
+	MOVB $1, ret+0(FP)
+	RET
+
+no:
+	MOVB $0, ret+0(FP)
+	RET
@@ -0,0 +1,31 @@
+//go:build !purego
+
+package jutil
+
+// escapeIndex returns the index of the first byte of b that has to be escaped,
+// or -1 if there are none.
+//
+// Inputs of at least 16 bytes are processed 16 bytes at a time with NEON
+// instructions.
+func escapeIndex(b []byte) int {
+	if len(b) >= 16 {
+		return escapeIndexNEON(b)
+	}
+	return escapeIndexSWAR(b)
+}
+
+// escapeIndexString is the equivalent of escapeIndex for strings.
+func escapeIndexString(s string) int {
+	if len(s) >= 16 {
+		return escapeIndexStringNEON(s)
+	}
+	return escapeIndexStringSWAR(s)
+}
+
+// The NEON functions require inputs of at least 16 bytes.
+
+//go:noescape
+func escapeIndexNEON(b []byte) int
+
+//go:noescape
+func escapeIndexStringNEON(s string) int
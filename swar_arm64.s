@@ -0,0 +1,79 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func escapeIndexNEON(b []byte) int
+TEXT ·escapeIndexNEON(SB), NOSPLIT, $0-32
+	MOVD b_base+0(FP), R0
+	MOVD b_len+8(FP), R1
+	MOVD $ret+24(FP), R8
+	B    escapeIndexBodyNEON<>(SB)
+
+// func escapeIndexStringNEON(s string) int
+TEXT ·escapeIndexStringNEON(SB), NOSPLIT, $0-24
+	MOVD s_base+0(FP), R0
+	MOVD s_len+8(FP), R1
+	MOVD $ret+16(FP), R8
+	B    escapeIndexBodyNEON<>(SB)
+
+// escapeIndexBodyNEON<> takes the address of the input in R0 and its length
+// (at least 16) in R1, it writes the index of the first byte that has to be
+// escaped, or -1, to the address in R8.
+//
+// The bytes below 64 are looked up in escapeTableNEON<> with a TBL
+// instruction (which yields zero for the bytes out of the table), and '\\'
+// is compared separately. Each byte of the result is narrowed to 4 bits so
+// the 16 bytes of a block fit in a 64 bit register, where the number of
+// trailing zeros gives the index of the first match. The last block overlaps
+// the previous one when the length isn't a multiple of 16, which is fine
+// since the bytes that it checks again had no match.
+TEXT escapeIndexBodyNEON<>(SB), NOSPLIT, $0-0
+	MOVD  $escapeTableNEON<>(SB), R2
+	VLD1  (R2), [V16.B16, V17.B16, V18.B16, V19.B16]
+	VMOVI $0x5c, V20.B16
+
+	MOVD R0, R3        // start of the input
+	ADD  R0, R1, R5    // end of the input
+	SUB  $16, R5, R4   // start of the last block
+
+loop:
+	VLD1  (R0), [V0.B16]
+	VTBL  V0.B16, [V16.B16, V17.B16, V18.B16, V19.B16], V1.B16
+	VCMEQ V0.B16, V20.B16, V2.B16
+	VORR  V1.B16, V2.B16, V1.B16
+	VSHRN $4, V1.H8, V1.B8
+	VMOV  V1.D[0], R6
+	CBNZ  R6, found
+
+	ADD  $16, R0
+	CMP  R4, R0
+	BLS  loop
+	CMP  R5, R0
+	BEQ  notfound
+	MOVD R4, R0
+	B    loop
+
+found:
+	RBIT R6, R6
+	CLZ  R6, R6
+	SUB  R3, R0, R0
+	ADD  R6>>2, R0, R0
+	MOVD R0, (R8)
+	RET
+
+notfound:
+	MOVD $-1, R0
+	MOVD R0, (R8)
+	RET
+
+// escapeTableNEON<> has 0xFF at the index of the bytes below 64 that have to
+// be escaped: \b \t \n \v \f \r, '"' and '/'.
+DATA escapeTableNEON<>+0x00(SB)/8, $0x0000000000000000
+DATA escapeTableNEON<>+0x08(SB)/8, $0x0000ffffffffffff
+DATA escapeTableNEON<>+0x10(SB)/8, $0x0000000000000000
+DATA escapeTableNEON<>+0x18(SB)/8, $0x0000000000000000
+DATA escapeTableNEON<>+0x20(SB)/8, $0x0000000000ff0000
+DATA escapeTableNEON<>+0x28(SB)/8, $0xff00000000000000
+DATA escapeTableNEON<>+0x30(SB)/8, $0x0000000000000000
+DATA escapeTableNEON<>+0x38(SB)/8, $0x0000000000000000
+GLOBL escapeTableNEON<>(SB), RODATA|NOPTR, $64
@@ -0,0 +1,14 @@
+//go:build (!amd64 && !arm64) || purego
+
+package jutil
+
+// escapeIndex returns the index of the first byte of b that has to be escaped,
+// or -1 if there are none.
+func escapeIndex(b []byte) int {
+	return escapeIndexSWAR(b)
+}
+
+// escapeIndexString is the equivalent of escapeIndex for strings.
+func escapeIndexString(s string) int {
+	return escapeIndexStringSWAR(s)
+}
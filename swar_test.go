@@ -0,0 +1,119 @@
+package jutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+// escapeIndexReference is the scalar implementation of escapeIndex.
+func escapeIndexReference(b []byte) int {
+	for i, c := range b {
+		switch c {
+		case '"', '/', '\\', '\n', '\t', '\r', '\v', '\b', '\f':
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeReference is the scalar implementation of Escape.
+func escapeReference(b []byte) []byte {
+	var e []byte
+
+	for _, c := range b {
+		switch c {
+		case '"', '/', '\\':
+			e = append(e, '\\', c)
+		case '\n':
+			e = append(e, '\\', 'n')
+		case '\t':
+			e = append(e, '\\', 't')
+		case '\r':
+			e = append(e, '\\', 'r')
+		case '\v':
+			e = append(e, '\\', 'v')
+		case '\b':
+			e = append(e, '\\', 'b')
+		case '\f':
+			e = append(e, '\\', 'f')
+		default:
+			e = append(e, c)
+		}
+	}
+
+	return e
+}
+
+// escapeIndexTests lists functions that must agree with escapeIndexReference,
+// escapeIndex and escapeIndexString use the assembly code when it is available
+// for the length of the input.
+var escapeIndexTests = []struct {
+	name  string
+	index func([]byte) int
+}{
+	{"escapeIndex", escapeIndex},
+	{"escapeIndexString", func(b []byte) int { return escapeIndexString(string(b)) }},
+	{"escapeIndexSWAR", escapeIndexSWAR},
+	{"escapeIndexStringSWAR", func(b []byte) int { return escapeIndexStringSWAR(string(b)) }},
+}
+
+func TestEscapeIndex(t *testing.T) {
+	// The lengths cover the SWAR words and the 16 and 32 bytes blocks of the
+	// assembly code, with and without a partial last block.
+	for _, n := range []int{7, 19, 32, 47, 64, 71} {
+		testEscapeIndex(t, make([]byte, n))
+	}
+}
+
+func testEscapeIndex(t *testing.T, b []byte) {
+	for c := 0; c != 256; c++ {
+		for i := range b {
+			for j := range b {
+				b[j] = 'A'
+			}
+			b[i] = byte(c)
+
+			// Put a byte that must be escaped after the one being tested to
+			// verify that false positives don't hide it.
+			if i+1 < len(b) {
+				b[i+1] = '"'
+			}
+
+			expect := escapeIndexReference(b)
+
+			for _, test := range escapeIndexTests {
+				if k := test.index(b); k != expect {
+					t.Fatalf("%s(%q): %d != %d", test.name, b, expect, k)
+				}
+			}
+		}
+	}
+}
+
+func FuzzEscape(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("Hello World!"))
+	f.Add([]byte("\x0e\x0f\x07\x08\x1f\"/\\"))
+	f.Add([]byte(escapeTextBody))
+	f.Add([]byte("0123456789abcdef0123456789abcdef0123456789\r"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		expectIndex := escapeIndexReference(b)
+
+		for _, test := range escapeIndexTests {
+			if i := test.index(b); i != expectIndex {
+				t.Fatalf("%s(%q): %d != %d", test.name, b, expectIndex, i)
+			}
+		}
+
+		expect := escapeReference(b)
+
+		if e := Escape(b); !bytes.Equal(e, expect) {
+			t.Fatalf("Escape(%q): %q != %q", b, expect, e)
+		}
+
+		if n := jsonLenString(string(b)); n != len(expect)+2 {
+			t.Fatalf("jsonLenString(%q): %d != %d", b, len(expect)+2, n)
+		}
+	})
+}
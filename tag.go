@@ -13,6 +13,9 @@ type Tag struct {
 	// Omitempty is true if the struct field json tag had `omitempty` set.
 	Omitempty bool
 
+	// Omitzero is true if the struct field json tag had `omitzero` set.
+	Omitzero bool
+
 	// Skip is true if the struct field json tag started with `-`.
 	Skip bool
 }
@@ -31,12 +34,23 @@ func ParseStructField(f reflect.StructField) Tag {
 // returining the results as a Tag value.
 func ParseTag(tag string) Tag {
 	name, tag := parseNextTagToken(tag)
-	token, _ := parseNextTagToken(tag)
-	return Tag{
-		Name:      name,
-		Skip:      name == "-",
-		Omitempty: token == "omitempty",
+	res := Tag{
+		Name: name,
+		Skip: name == "-",
 	}
+
+	for len(tag) != 0 {
+		var token string
+
+		switch token, tag = parseNextTagToken(tag); token {
+		case "omitempty":
+			res.Omitempty = true
+		case "omitzero":
+			res.Omitzero = true
+		}
+	}
+
+	return res
 }
 
 func parseNextTagToken(tag string) (token string, next string) {
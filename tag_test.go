@@ -34,6 +34,14 @@ func TestParseTag(t *testing.T) {
 			tag: "-,omitempty",
 			res: Tag{Name: "-", Omitempty: true, Skip: true},
 		},
+		{
+			tag: "hello,omitzero",
+			res: Tag{Name: "hello", Omitzero: true},
+		},
+		{
+			tag: ",string,omitzero,omitempty",
+			res: Tag{Omitempty: true, Omitzero: true},
+		},
 	}
 
 	for _, test := range tests {
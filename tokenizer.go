@@ -0,0 +1,353 @@
+package jutil
+
+// TokenKind is an enumeration representing the kinds of tokens produced by a
+// Tokenizer.
+type TokenKind int
+
+const (
+	// InvalidToken is the kind of the token before the first call to Next.
+	InvalidToken TokenKind = iota
+
+	// ObjectStart is the kind of `{` delimiters.
+	ObjectStart
+
+	// ObjectEnd is the kind of `}` delimiters.
+	ObjectEnd
+
+	// ArrayStart is the kind of `[` delimiters.
+	ArrayStart
+
+	// ArrayEnd is the kind of `]` delimiters.
+	ArrayEnd
+
+	// Comma is the kind of `,` separators.
+	Comma
+
+	// Colon is the kind of `:` separators.
+	Colon
+
+	// String is the kind of string tokens, including object keys.
+	String
+
+	// Number is the kind of number tokens.
+	Number
+
+	// True is the kind of `true` literals.
+	True
+
+	// False is the kind of `false` literals.
+	False
+
+	// Null is the kind of `null` literals.
+	Null
+)
+
+// String returns a human-readable representation of the token kind.
+func (k TokenKind) String() string {
+	switch k {
+	case ObjectStart:
+		return "'{'"
+	case ObjectEnd:
+		return "'}'"
+	case ArrayStart:
+		return "'['"
+	case ArrayEnd:
+		return "']'"
+	case Comma:
+		return "','"
+	case Colon:
+		return "':'"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case True:
+		return "true"
+	case False:
+		return "false"
+	case Null:
+		return "null"
+	}
+	return "invalid token"
+}
+
+// Tokenizer splits raw JSON content into tokens.
+//
+// The tokens are exposed as slices of the input, which means that the
+// tokenizer doesn't allocate memory while scanning valid content (unless the
+// input is nested more than 64 levels deep), it is however the responsibility
+// of the program to not retain the token values if the input buffer is reused.
+// String tokens are left quoted, use Unquote to decode them.
+//
+// The tokenizer verifies the syntax of the input as it scans it, it supports
+// reading sequences of top-level values separated by whitespaces.
+//
+// Here is a typical use of a Tokenizer:
+//
+//	t := jutil.NewTokenizer(b)
+//
+//	for t.Next() {
+//		switch t.Kind {
+//		...
+//		}
+//	}
+//
+//	if t.Err != nil {
+//		...
+//	}
+type Tokenizer struct {
+	// The kind of the current token.
+	Kind TokenKind
+
+	// The bytes of the current token, as a slice of the input.
+	Value []byte
+
+	// The byte offset of the current token in the input.
+	Offset int
+
+	// The number of objects and arrays enclosing the current token, the
+	// delimiters of top-level values have a depth of zero.
+	Depth int
+
+	// True if the current token is a string used as an object key.
+	IsKey bool
+
+	// The syntax error that stopped the tokenizer, nil if the end of the input
+	// was reached successfully.
+	Err error
+
+	input []byte
+	pos   int
+	state tokenizerState
+	depth int
+	stack []uint64
+	inner [1]uint64
+}
+
+type tokenizerState int
+
+const (
+	expectValue tokenizerState = iota
+	expectValueOrArrayEnd
+	expectKeyOrObjectEnd
+	expectKey
+	expectColon
+	expectCommaOrEnd
+)
+
+// NewTokenizer creates a new tokenizer reading from b.
+func NewTokenizer(b []byte) *Tokenizer {
+	t := &Tokenizer{}
+	t.Reset(b)
+	return t
+}
+
+// Reset resets the tokenizer to read from b, reusing the memory that it had
+// allocated.
+func (t *Tokenizer) Reset(b []byte) {
+	stack := t.stack
+	if stack == nil {
+		stack = t.inner[:]
+	}
+	*t = Tokenizer{input: b, stack: stack[:cap(stack)]}
+}
+
+// Pos returns the byte offset in the input where the next token is going to be
+// read from.
+func (t *Tokenizer) Pos() int {
+	return t.pos
+}
+
+// Next moves the tokenizer to the next token of the input, returning false
+// when the end of the input was reached or a syntax error occurred, in which
+// case t.Err is set.
+func (t *Tokenizer) Next() bool {
+	if t.Err != nil {
+		return false
+	}
+
+	b := t.input
+	i := skipSpaces(b, t.pos)
+
+	if i == len(b) {
+		if t.depth != 0 || t.state != expectValue {
+			t.unexpected(i)
+		}
+		t.pos = i
+		return false
+	}
+
+	j := i + 1
+	t.Offset = i
+	t.Depth = t.depth
+	t.IsKey = false
+
+	var err error
+
+	switch c := b[i]; c {
+	case '{', '[':
+		if !t.expectingValue() {
+			return t.unexpected(i)
+		}
+		if c == '{' {
+			t.Kind, t.state = ObjectStart, expectKeyOrObjectEnd
+		} else {
+			t.Kind, t.state = ArrayStart, expectValueOrArrayEnd
+		}
+		t.push(c == '{')
+
+	case '}', ']':
+		object := c == '}'
+		switch t.state {
+		case expectCommaOrEnd:
+			if t.inObject() != object {
+				return t.unexpected(i)
+			}
+		case expectKeyOrObjectEnd:
+			if !object {
+				return t.unexpected(i)
+			}
+		case expectValueOrArrayEnd:
+			if object {
+				return t.unexpected(i)
+			}
+		default:
+			return t.unexpected(i)
+		}
+		if object {
+			t.Kind = ObjectEnd
+		} else {
+			t.Kind = ArrayEnd
+		}
+		t.depth--
+		t.Depth = t.depth
+		t.endValue()
+
+	case ',':
+		if t.state != expectCommaOrEnd {
+			return t.unexpected(i)
+		}
+		if t.Kind = Comma; t.inObject() {
+			t.state = expectKey
+		} else {
+			t.state = expectValue
+		}
+
+	case ':':
+		if t.state != expectColon {
+			return t.unexpected(i)
+		}
+		t.Kind, t.state = Colon, expectValue
+
+	case '"':
+		switch t.state {
+		case expectKey, expectKeyOrObjectEnd:
+			t.IsKey, t.state = true, expectColon
+		default:
+			if !t.expectingValue() {
+				return t.unexpected(i)
+			}
+			t.endValue()
+		}
+		t.Kind = String
+		j, err = scanString(b, i)
+
+	case 't', 'f', 'n':
+		if !t.expectingValue() {
+			return t.unexpected(i)
+		}
+		switch c {
+		case 't':
+			t.Kind = True
+			j, err = scanLiteral(b, i, "true")
+		case 'f':
+			t.Kind = False
+			j, err = scanLiteral(b, i, "false")
+		default:
+			t.Kind = Null
+			j, err = scanLiteral(b, i, "null")
+		}
+		t.endValue()
+
+	default:
+		if !t.expectingValue() || (c != '-' && !isDigit(c)) {
+			return t.unexpected(i)
+		}
+		t.Kind = Number
+		j, err = scanNumber(b, i)
+		t.endValue()
+	}
+
+	if err != nil {
+		return t.fail(err)
+	}
+
+	t.Value = b[i:j]
+	t.pos = j
+	return true
+}
+
+func (t *Tokenizer) expectingValue() bool {
+	return t.state == expectValue || t.state == expectValueOrArrayEnd
+}
+
+func (t *Tokenizer) endValue() {
+	if t.depth == 0 {
+		t.state = expectValue
+	} else {
+		t.state = expectCommaOrEnd
+	}
+}
+
+func (t *Tokenizer) push(object bool) {
+	i, bit := t.depth/64, uint64(1)<<uint(t.depth%64)
+
+	if i == len(t.stack) {
+		t.stack = append(t.stack, 0)
+	}
+
+	if object {
+		t.stack[i] |= bit
+	} else {
+		t.stack[i] &^= bit
+	}
+
+	t.depth++
+}
+
+func (t *Tokenizer) inObject() bool {
+	d := t.depth - 1
+	return (t.stack[d/64] & (uint64(1) << uint(d%64))) != 0
+}
+
+func (t *Tokenizer) unexpected(i int) bool {
+	var ctx *syntaxContext
+
+	switch t.state {
+	case expectValue:
+		ctx = ctxValue
+	case expectValueOrArrayEnd:
+		ctx = ctxValueOrEnd
+	case expectKey:
+		ctx = ctxKey
+	case expectKeyOrObjectEnd:
+		ctx = ctxKeyOrEnd
+	case expectColon:
+		ctx = ctxColon
+	default:
+		if t.inObject() {
+			ctx = ctxObjectNext
+		} else {
+			ctx = ctxArrayNext
+		}
+	}
+
+	return t.fail(syntaxErrorChar(t.input, i, ctx))
+}
+
+func (t *Tokenizer) fail(err error) bool {
+	t.Kind = InvalidToken
+	t.Value = nil
+	t.Err = err
+	return false
+}
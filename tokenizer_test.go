@@ -0,0 +1,215 @@
+package jutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	type token struct {
+		kind  TokenKind
+		value string
+		depth int
+		key   bool
+	}
+
+	input := `{"a": [1, -2.5e3, "x\"y"], "b": {"c": true, "d": false}, "e": null, "f": {}, "g": []}`
+
+	tokens := []token{
+		{ObjectStart, `{`, 0, false},
+		{String, `"a"`, 1, true},
+		{Colon, `:`, 1, false},
+		{ArrayStart, `[`, 1, false},
+		{Number, `1`, 2, false},
+		{Comma, `,`, 2, false},
+		{Number, `-2.5e3`, 2, false},
+		{Comma, `,`, 2, false},
+		{String, `"x\"y"`, 2, false},
+		{ArrayEnd, `]`, 1, false},
+		{Comma, `,`, 1, false},
+		{String, `"b"`, 1, true},
+		{Colon, `:`, 1, false},
+		{ObjectStart, `{`, 1, false},
+		{String, `"c"`, 2, true},
+		{Colon, `:`, 2, false},
+		{True, `true`, 2, false},
+		{Comma, `,`, 2, false},
+		{String, `"d"`, 2, true},
+		{Colon, `:`, 2, false},
+		{False, `false`, 2, false},
+		{ObjectEnd, `}`, 1, false},
+		{Comma, `,`, 1, false},
+		{String, `"e"`, 1, true},
+		{Colon, `:`, 1, false},
+		{Null, `null`, 1, false},
+		{Comma, `,`, 1, false},
+		{String, `"f"`, 1, true},
+		{Colon, `:`, 1, false},
+		{ObjectStart, `{`, 1, false},
+		{ObjectEnd, `}`, 1, false},
+		{Comma, `,`, 1, false},
+		{String, `"g"`, 1, true},
+		{Colon, `:`, 1, false},
+		{ArrayStart, `[`, 1, false},
+		{ArrayEnd, `]`, 1, false},
+		{ObjectEnd, `}`, 0, false},
+	}
+
+	tok := NewTokenizer([]byte(input))
+
+	for i, expect := range tokens {
+		if !tok.Next() {
+			t.Fatalf("unexpected end of tokens after %d tokens: %v", i, tok.Err)
+		}
+
+		found := token{tok.Kind, string(tok.Value), tok.Depth, tok.IsKey}
+
+		if found != expect {
+			t.Errorf("token #%d: %+v != %+v", i, expect, found)
+		}
+
+		if input[tok.Offset:tok.Offset+len(tok.Value)] != string(tok.Value) {
+			t.Errorf("token #%d: invalid offset: %d", i, tok.Offset)
+		}
+	}
+
+	if tok.Next() {
+		t.Errorf("unexpected token after the end of the input: %s", tok.Kind)
+	}
+
+	if tok.Err != nil {
+		t.Error(tok.Err)
+	}
+}
+
+func TestTokenizerStream(t *testing.T) {
+	tok := NewTokenizer([]byte("1 \"2\"\n[3] {}"))
+	n := 0
+
+	for tok.Next() {
+		n++
+	}
+
+	if tok.Err != nil {
+		t.Error(tok.Err)
+	}
+
+	if n != 7 {
+		t.Errorf("invalid number of tokens: %d", n)
+	}
+}
+
+func TestTokenizerDeepNesting(t *testing.T) {
+	const depth = 200
+	input := strings.Repeat(`[{"a":`, depth) + `0` + strings.Repeat(`}]`, depth)
+
+	tok := NewTokenizer([]byte(input))
+	max := 0
+
+	for tok.Next() {
+		if tok.Depth > max {
+			max = tok.Depth
+		}
+	}
+
+	if tok.Err != nil {
+		t.Error(tok.Err)
+	}
+
+	if max != 2*depth {
+		t.Errorf("invalid maximum depth: %d", max)
+	}
+}
+
+func TestTokenizerSyntaxError(t *testing.T) {
+	tests := []struct {
+		in     string
+		line   int
+		column int
+	}{
+		{in: `{`, line: 1, column: 2},
+		{in: `}`, line: 1, column: 1},
+		{in: `[1,]`, line: 1, column: 4},
+		{in: `[1}`, line: 1, column: 3},
+		{in: `{"a" 1}`, line: 1, column: 6},
+		{in: `{"a":1,}`, line: 1, column: 8},
+		{in: `{1:2}`, line: 1, column: 2},
+		{in: "[\n  tru\n]", line: 2, column: 6},
+		{in: "{\n\t\"a\": 01\n}", line: 2, column: 8},
+		{in: `"abc`, line: 1, column: 5},
+		{in: `"\x"`, line: 1, column: 3},
+		{in: `[1 2]`, line: 1, column: 4},
+		{in: `-`, line: 1, column: 2},
+		{in: `1.`, line: 1, column: 3},
+		{in: `1e+`, line: 1, column: 4},
+	}
+
+	for _, test := range tests {
+		tok := NewTokenizer([]byte(test.in))
+
+		for tok.Next() {
+		}
+
+		var e *SyntaxError
+
+		if !errors.As(tok.Err, &e) {
+			t.Errorf("%#v: expected a syntax error but got %v", test.in, tok.Err)
+		} else if e.Line != test.line || e.Column != test.column {
+			t.Errorf("%#v: invalid error location: %d:%d != %d:%d (%s)", test.in, test.line, test.column, e.Line, e.Column, e)
+		}
+	}
+}
+
+func TestTokenizerAllocations(t *testing.T) {
+	input := []byte(`{"a":[1,2,3,{"b":null}],"c":"hello\nworld","d":[[[[true]]]]}`)
+	tok := NewTokenizer(nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for tok.Reset(input); tok.Next(); {
+		}
+	})
+
+	if allocs != 0 {
+		t.Errorf("the tokenizer allocated memory: %g", allocs)
+	}
+}
+
+func BenchmarkTokenizer(b *testing.B) {
+	input := []byte(longJSONDocument)
+	tok := NewTokenizer(nil)
+
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i != b.N; i++ {
+		for tok.Reset(input); tok.Next(); {
+		}
+	}
+}
+
+const longJSONDocument = `{
+	"type": "track",
+	"event": "Order Completed",
+	"userId": "019mr8mf4r",
+	"timestamp": "2016-04-26T09:27:15.000Z",
+	"properties": {
+		"orderId": "50314b8e9bcf000000000000",
+		"total": 27.5,
+		"revenue": 25.00,
+		"shipping": 3,
+		"tax": 2,
+		"discount": 2.5,
+		"coupon": "hasbros",
+		"currency": "USD",
+		"products": [
+			{"id": "507f1f77bcf86cd799439011", "sku": "45790-32", "name": "Monopoly: 3rd Edition", "price": 19, "quantity": 1, "category": "Games"},
+			{"id": "505bd76785ebb509fc183733", "sku": "46493-32", "name": "Uno Card Game", "price": 3, "quantity": 2, "category": "Games"}
+		]
+	},
+	"context": {
+		"ip": "8.8.8.8",
+		"userAgent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/50.0.2661.86 Safari/537.36",
+		"library": {"name": "analytics.js", "version": "2.11.1"},
+		"traits": {"email": "peter@example.com", "vip": true, "logins": null}
+	}
+}`
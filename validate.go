@@ -0,0 +1,29 @@
+package jutil
+
+// Valid returns true if b contains a single valid JSON value, optionally
+// surrounded by whitespaces.
+//
+// The function accepts the same inputs as json.Valid but is faster because it
+// doesn't go through a state machine to scan the input.
+func Valid(b []byte) bool {
+	return Validate(b) == nil
+}
+
+// Validate checks that b contains a single valid JSON value, optionally
+// surrounded by whitespaces.
+//
+// When the input is invalid the function returns a *SyntaxError which carries
+// the location of the error, the unexpected character and a description of the
+// tokens that were expected.
+func Validate(b []byte) error {
+	i, err := skipValue(b, skipSpaces(b, 0))
+	if err != nil {
+		return err
+	}
+
+	if i = skipSpaces(b, i); i != len(b) {
+		return syntaxErrorChar(b, i, ctxEnd)
+	}
+
+	return nil
+}
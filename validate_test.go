@@ -0,0 +1,169 @@
+package jutil
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var validTests = []struct {
+	in    string
+	valid bool
+}{
+	{``, false},
+	{` `, false},
+	{`null`, true},
+	{` true `, true},
+	{"\t\r\nfalse\n", true},
+	{`0`, true},
+	{`-0.1e+10`, true},
+	{`01`, false},
+	{`1.`, false},
+	{`.1`, false},
+	{`+1`, false},
+	{`""`, true},
+	{`"\u00e9\n\/"`, true},
+	{`"\x"`, false},
+	{"\"\t\"", false},
+	{`"abc`, false},
+	{`[]`, true},
+	{`[1,2,[3]]`, true},
+	{`[1,]`, false},
+	{`[,1]`, false},
+	{`{}`, true},
+	{`{"a":{"b":[]}}`, true},
+	{`{"a":}`, false},
+	{`{"a" 1}`, false},
+	{`{a:1}`, false},
+	{`{"a":1,}`, false},
+	{`{"a":1}}`, false},
+	{`1 2`, false},
+	{`nul`, false},
+	{`nulll`, false},
+	{strings.Repeat(`[`, 10000) + strings.Repeat(`]`, 10000), true},
+	{strings.Repeat(`[`, 10001) + strings.Repeat(`]`, 10001), false},
+}
+
+func TestValid(t *testing.T) {
+	for _, test := range validTests {
+		if valid := Valid([]byte(test.in)); valid != test.valid {
+			t.Errorf("%.40q: expected valid=%t", test.in, test.valid)
+		}
+		if valid := json.Valid([]byte(test.in)); valid != test.valid {
+			t.Errorf("%.40q: json.Valid disagrees with the test", test.in)
+		}
+	}
+}
+
+func TestValidateError(t *testing.T) {
+	tests := []struct {
+		in  string
+		err SyntaxError
+	}{
+		{
+			in: ``,
+			err: SyntaxError{
+				Offset:   0,
+				Line:     1,
+				Column:   1,
+				Expected: []string{"'{'", "'['", "string", "number", "true", "false", "null"},
+			},
+		},
+		{
+			in: "{\n  \"a\": 1,\n  \"b\" 2\n}",
+			err: SyntaxError{
+				Offset:   18,
+				Line:     3,
+				Column:   7,
+				Char:     '2',
+				Expected: []string{"':'"},
+			},
+		},
+		{
+			in: "[1, 2\n",
+			err: SyntaxError{
+				Offset:   6,
+				Line:     2,
+				Column:   1,
+				Expected: []string{"','", "']'"},
+			},
+		},
+		{
+			in: `{"a":1} x`,
+			err: SyntaxError{
+				Offset:   8,
+				Line:     1,
+				Column:   9,
+				Char:     'x',
+				Expected: []string{"end of input"},
+			},
+		},
+		{
+			in: `[tru]`,
+			err: SyntaxError{
+				Offset:   4,
+				Line:     1,
+				Column:   5,
+				Char:     ']',
+				Expected: []string{"'e'"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var e *SyntaxError
+
+		if err := Validate([]byte(test.in)); !errors.As(err, &e) {
+			t.Errorf("%#v: expected a syntax error but got %v", test.in, err)
+		} else {
+			e.Msg = ""
+			if !reflect.DeepEqual(*e, test.err) {
+				t.Errorf("%#v: invalid error:\n%+v\n%+v", test.in, test.err, *e)
+			}
+		}
+	}
+}
+
+func FuzzValidate(f *testing.F) {
+	for _, test := range validTests {
+		f.Add([]byte(test.in))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		err := Validate(b)
+
+		if valid := json.Valid(b); valid != (err == nil) {
+			t.Fatalf("%q: json.Valid=%t but Validate returned %v", b, valid, err)
+		}
+
+		if err != nil {
+			var e *SyntaxError
+			if !errors.As(err, &e) {
+				t.Fatalf("%q: expected a syntax error but got %v", b, err)
+			}
+			if e.Offset < 0 || e.Offset > len(b) {
+				t.Fatalf("%q: syntax error offset out of range: %d", b, e.Offset)
+			}
+		}
+	})
+}
+
+func BenchmarkValid(b *testing.B) {
+	input := []byte(longJSONDocument)
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i != b.N; i++ {
+		Valid(input)
+	}
+}
+
+func BenchmarkValidStd(b *testing.B) {
+	input := []byte(longJSONDocument)
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i != b.N; i++ {
+		json.Valid(input)
+	}
+}
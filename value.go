@@ -0,0 +1,307 @@
+package jutil
+
+import (
+	"strconv"
+)
+
+// ValueType is an enumeration representing the types of JSON values.
+type ValueType int
+
+const (
+	// InvalidValue is the type of missing or malformed values.
+	InvalidValue ValueType = iota
+
+	// NullValue is the type of `null`.
+	NullValue
+
+	// BoolValue is the type of `true` and `false`.
+	BoolValue
+
+	// NumberValue is the type of numbers.
+	NumberValue
+
+	// StringValue is the type of strings.
+	StringValue
+
+	// ArrayValue is the type of arrays.
+	ArrayValue
+
+	// ObjectValue is the type of objects.
+	ObjectValue
+)
+
+// String returns a human-readable representation of the value type.
+func (t ValueType) String() string {
+	switch t {
+	case NullValue:
+		return "null"
+	case BoolValue:
+		return "bool"
+	case NumberValue:
+		return "number"
+	case StringValue:
+		return "string"
+	case ArrayValue:
+		return "array"
+	case ObjectValue:
+		return "object"
+	}
+	return "invalid"
+}
+
+// Value is a raw JSON value which is decoded lazily when its content is
+// accessed.
+//
+// Lookups scan the raw bytes without decoding or allocating memory (unless the
+// keys of objects contain escape sequences), so a program that only needs a
+// few fields of a large document doesn't pay for decoding the rest. The
+// methods return zero-values when the value doesn't have the expected type,
+// missing values are represented by empty Value slices.
+//
+// Value implements Lengther and json.Marshaler, so it can be passed to Length
+// and the encoders without being copied or decoded.
+type Value []byte
+
+// Type returns the type of v.
+func (v Value) Type() ValueType {
+	i := skipSpaces(v, 0)
+
+	if i == len(v) {
+		return InvalidValue
+	}
+
+	switch c := v[i]; c {
+	case 'n':
+		return NullValue
+	case 't', 'f':
+		return BoolValue
+	case '"':
+		return StringValue
+	case '[':
+		return ArrayValue
+	case '{':
+		return ObjectValue
+	default:
+		if c == '-' || isDigit(c) {
+			return NumberValue
+		}
+		return InvalidValue
+	}
+}
+
+// Exists returns true if v is not a missing value.
+func (v Value) Exists() bool {
+	return len(v) != 0
+}
+
+// Get returns the value at the path formed by keys, where each key is the name
+// of a member of nested objects. Missing values are returned if any of the
+// keys doesn't exist.
+func (v Value) Get(keys ...string) Value {
+	for _, k := range keys {
+		i := skipSpaces(v, 0)
+
+		if i == len(v) || v[i] != '{' {
+			return nil
+		}
+
+		i, err := pointerGetRawMember(v, i, k)
+		if err != nil {
+			return nil
+		}
+
+		if v = v.at(i); v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+// Index returns the element at index i of the array represented by v, or a
+// missing value if v is not an array or i is out of bounds.
+func (v Value) Index(i int) Value {
+	j := skipSpaces(v, 0)
+
+	if i < 0 || j == len(v) || v[j] != '[' {
+		return nil
+	}
+
+	j, err := rawElement(v, j, i)
+	if err != nil {
+		return nil
+	}
+
+	return v.at(j)
+}
+
+// ForEach calls f for each member of the object or element of the array
+// represented by v, stopping if f returns false. When iterating over objects
+// the key is passed as a quoted string value, it is nil for arrays.
+func (v Value) ForEach(f func(key Value, value Value) bool) {
+	var err error
+	var j int
+
+	b := []byte(v)
+	i := skipSpaces(b, 0)
+
+	if i == len(b) || (b[i] != '{' && b[i] != '[') {
+		return
+	}
+
+	object := b[i] == '{'
+
+	for i = skipSpaces(b, i+1); i < len(b); {
+		var key Value
+
+		if b[i] == '}' || b[i] == ']' {
+			return
+		}
+
+		if object {
+			if j, err = scanString(b, i); err != nil {
+				return
+			}
+			key = Value(b[i:j])
+
+			if i = skipSpaces(b, j); i == len(b) || b[i] != ':' {
+				return
+			}
+			i = skipSpaces(b, i+1)
+		}
+
+		if j, err = skipValue(b, i); err != nil {
+			return
+		}
+
+		if !f(key, Value(b[i:j])) {
+			return
+		}
+
+		if i = skipSpaces(b, j); i < len(b) && b[i] == ',' {
+			i = skipSpaces(b, i+1)
+		}
+	}
+}
+
+// String returns the unquoted content of v if it's a string, or its raw
+// representation for other types. Missing and null values return an empty
+// string.
+func (v Value) String() string {
+	switch v.Type() {
+	case StringValue:
+		s, err := Unquote(v.trim())
+		if err != nil {
+			return ""
+		}
+		return string(s)
+	case NullValue, InvalidValue:
+		return ""
+	}
+	return string(v.trim())
+}
+
+// Int returns the integer value of v if it's a number, truncating fractional
+// parts, or zero otherwise.
+func (v Value) Int() int64 {
+	if v.Type() != NumberValue {
+		return 0
+	}
+	s := string(v.trim())
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return int64(f)
+}
+
+// Float returns the floating point value of v if it's a number, or zero
+// otherwise.
+func (v Value) Float() float64 {
+	if v.Type() != NumberValue {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(string(v.trim()), 64)
+	return f
+}
+
+// Bool returns true if v is the `true` literal.
+func (v Value) Bool() bool {
+	return string(v.trim()) == "true"
+}
+
+// LengthJSON satisfies the Lengther interface, the length is the one of the
+// compacted representation of v, which is what the encoders output.
+func (v Value) LengthJSON() (n int) {
+	if len(v) == 0 {
+		return jsonLenNull()
+	}
+
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case ' ', '\t', '\n', '\r':
+		case '"':
+			j, _ := scanString(v, i)
+			n += j - i
+			i = j - 1
+		default:
+			n++
+		}
+	}
+
+	return
+}
+
+// LengthJSONWithOptions satisfies the OptionsLengther interface. The value is
+// compacted by the encoders, which also escape the HTML characters and the
+// U+2028 and U+2029 line separators that it contains when EscapeHTML is set.
+// An error is returned if v isn't valid JSON.
+func (v Value) LengthJSONWithOptions(o *LengthOptions) (n int, err error) {
+	if len(v) == 0 {
+		return jsonLenNull(), nil
+	}
+	if err = Validate(v); err != nil {
+		return
+	}
+
+	n = v.LengthJSON()
+
+	if o.EscapeHTML {
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; {
+			case c == '<' || c == '>' || c == '&':
+				n += 5 // \u00XX
+			case c == 0xE2 && i+2 < len(v) && v[i+1] == 0x80 && v[i+2]&^1 == 0xA8:
+				n += 3 // \u202X
+				i += 2
+			}
+		}
+	}
+
+	return
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (v Value) MarshalJSON() ([]byte, error) {
+	if len(v) == 0 {
+		return []byte("null"), nil
+	}
+	return v, nil
+}
+
+// at returns the value starting at offset i of v.
+func (v Value) at(i int) Value {
+	j, err := skipValue(v, i)
+	if err != nil {
+		return nil
+	}
+	return v[i:j]
+}
+
+// trim returns v without leading and trailing whitespaces.
+func (v Value) trim() []byte {
+	i, j := skipSpaces(v, 0), len(v)
+	for j > i && (v[j-1] == ' ' || v[j-1] == '\t' || v[j-1] == '\n' || v[j-1] == '\r') {
+		j--
+	}
+	return v[i:j]
+}
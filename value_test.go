@@ -0,0 +1,176 @@
+package jutil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const valueDocument = ` {
+	"name": "Luke \"Skywalker\"",
+	"height": 172,
+	"mass": 77.5,
+	"jedi": true,
+	"master": null,
+	"films": [ "A New Hope", "The Empire Strikes Back", "Return of the Jedi" ],
+	"ship": { "name": "X-wing", "crew": [ { "id": 1 }, { "id": 2 } ] },
+	"esc\u0061ped": "yes"
+} `
+
+func TestValueGet(t *testing.T) {
+	v := Value(valueDocument)
+
+	tests := []struct {
+		value  Value
+		typ    ValueType
+		expect string
+	}{
+		{v, ObjectValue, valueDocument[1 : len(valueDocument)-1]},
+		{v.Get(), ObjectValue, valueDocument[1 : len(valueDocument)-1]},
+		{v.Get("name"), StringValue, `Luke "Skywalker"`},
+		{v.Get("height"), NumberValue, `172`},
+		{v.Get("jedi"), BoolValue, `true`},
+		{v.Get("master"), NullValue, ``},
+		{v.Get("films").Index(1), StringValue, `The Empire Strikes Back`},
+		{v.Get("films").Index(3), InvalidValue, ``},
+		{v.Get("films").Index(-1), InvalidValue, ``},
+		{v.Get("ship", "name"), StringValue, `X-wing`},
+		{v.Get("ship", "crew").Index(1).Get("id"), NumberValue, `2`},
+		{v.Get("escaped"), StringValue, `yes`},
+		{v.Get("missing"), InvalidValue, ``},
+		{v.Get("name", "missing"), InvalidValue, ``},
+		{v.Index(0), InvalidValue, ``},
+		{Value(nil).Get("a"), InvalidValue, ``},
+		{Value(`{"a":}`).Get("a"), InvalidValue, ``},
+	}
+
+	for _, test := range tests {
+		if typ := test.value.Type(); typ != test.typ {
+			t.Errorf("%q: invalid type: %s != %s", test.value, test.typ, typ)
+		}
+		if s := test.value.String(); s != test.expect && test.typ != ObjectValue {
+			t.Errorf("%q: invalid string: %q != %q", test.value, test.expect, s)
+		}
+		if test.value.Exists() != (test.typ != InvalidValue) {
+			t.Errorf("%q: invalid existence", test.value)
+		}
+	}
+}
+
+func TestValueScalars(t *testing.T) {
+	v := Value(valueDocument)
+
+	if i := v.Get("height").Int(); i != 172 {
+		t.Error("invalid int:", i)
+	}
+	if i := v.Get("mass").Int(); i != 77 {
+		t.Error("invalid truncated int:", i)
+	}
+	if i := v.Get("name").Int(); i != 0 {
+		t.Error("invalid int from string:", i)
+	}
+	if f := v.Get("mass").Float(); f != 77.5 {
+		t.Error("invalid float:", f)
+	}
+	if b := v.Get("jedi").Bool(); !b {
+		t.Error("invalid bool:", b)
+	}
+	if b := v.Get("master").Bool(); b {
+		t.Error("invalid bool from null:", b)
+	}
+}
+
+func TestValueForEach(t *testing.T) {
+	v := Value(valueDocument)
+
+	var keys []string
+	v.ForEach(func(k Value, _ Value) bool {
+		keys = append(keys, k.String())
+		return true
+	})
+
+	if len(keys) != 8 || keys[0] != "name" || keys[7] != "escaped" {
+		t.Error("invalid keys:", keys)
+	}
+
+	var films []string
+	v.Get("films").ForEach(func(k Value, e Value) bool {
+		if k != nil {
+			t.Error("unexpected key in array:", k)
+		}
+		films = append(films, e.String())
+		return len(films) != 2
+	})
+
+	if len(films) != 2 || films[1] != "The Empire Strikes Back" {
+		t.Error("invalid elements:", films)
+	}
+
+	Value(`[]`).ForEach(func(Value, Value) bool {
+		t.Error("unexpected call on empty array")
+		return true
+	})
+}
+
+func TestValueMarshal(t *testing.T) {
+	tests := []Value{
+		nil,
+		Value(`null`),
+		Value(valueDocument),
+		Value(` [ "a b" , { } , 1 ] `),
+		Value(`{"a": "<b>", "c": " &"}`),
+	}
+
+	for _, test := range tests {
+		expect, err := json.Marshal(test)
+		if err != nil {
+			t.Errorf("%q: %s", test, err)
+			continue
+		}
+
+		if b, err := Marshal(test); err != nil {
+			t.Errorf("%q: %s", test, err)
+		} else if string(b) != string(expect) {
+			t.Errorf("%q: invalid output:\n%s\n%s", test, expect, b)
+		}
+
+		if n, err := Length(test); err != nil {
+			t.Errorf("%q: %s", test, err)
+		} else if n != len(expect)-escapedHTMLLength(test) {
+			t.Errorf("%q: invalid length: %d != %d", test, len(expect), n)
+		}
+
+		if n, err := LengthWithOptions(test, StdLengthOptions); err != nil {
+			t.Errorf("%q: %s", test, err)
+		} else if n != len(expect) {
+			t.Errorf("%q: invalid length with the standard options: %d != %d", test, len(expect), n)
+		}
+	}
+
+	if _, err := LengthWithOptions(Value(`{"a":}`), StdLengthOptions); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+// escapedHTMLLength returns the number of bytes added to v by escaping its HTML
+// characters and line separators, which Length doesn't do.
+func escapedHTMLLength(v Value) int {
+	return 5*strings.Count(string(v), "<") + 5*strings.Count(string(v), ">") + 5*strings.Count(string(v), "&") +
+		3*strings.Count(string(v), "\u2028") + 3*strings.Count(string(v), "\u2029")
+}
+
+func TestValueAllocs(t *testing.T) {
+	v := Value(valueDocument)
+
+	if n := testing.AllocsPerRun(100, func() { v.Get("ship", "crew").Index(1).Get("id").Int() }); n != 0 {
+		t.Error("too many memory allocations:", n)
+	}
+}
+
+func BenchmarkValueGet(b *testing.B) {
+	v := Value(longJSONDocument)
+
+	for i := 0; i != b.N; i++ {
+		v.Get("missing")
+	}
+}